@@ -23,7 +23,9 @@ type ApplyOpts struct {
 }
 
 // Apply applies the configuration o to c.  Fields are copied over if they are
-// not nil in o, otherwise left untouched.  Labels in o should not include the
+// not nil in o, otherwise left untouched.  Pre, Post, W and F are copied with
+// their Specs, so o should be resolved (see Config.Resolve) if it was decoded
+// from json.  Labels in o should not include the
 // package name, but if they start with '.', they are expanded with the package
 // name of 'c' when copied to c's Labels.
 //
@@ -38,15 +40,37 @@ func (c *Config) Apply(o *Config, opts *ApplyOpts) {
 	}
 	if o.W != nil {
 		c.W = o.W
+		c.WSpec = o.WSpec
 	}
 	if o.F != nil {
 		c.F = o.F
+		c.FSpec = o.FSpec
+	}
+	if o.FloatFormat != "" {
+		c.FloatFormat = o.FloatFormat
+	}
+	if o.FloatPrec != 0 {
+		c.FloatPrec = o.FloatPrec
+	}
+	if o.NoValidate != nil {
+		c.NoValidate = cloneBool(o.NoValidate)
+	}
+	if o.EscapeHTML != nil {
+		c.EscapeHTML = cloneBool(o.EscapeHTML)
+	}
+	if o.NonFinite != "" {
+		c.NonFinite = o.NonFinite
 	}
 	if o.Pre != nil {
 		c.Pre = append([]Middleware{}, o.Pre...)
+		c.PreSpec = append([]Spec(nil), o.PreSpec...)
 	}
 	if o.Post != nil {
 		c.Post = append([]Middleware{}, o.Post...)
+		c.PostSpec = append([]Spec(nil), o.PostSpec...)
+	}
+	if o.Sinks != nil {
+		c.Sinks = applySinks(c.Sinks, o.Sinks)
 	}
 	if o.Labels == nil {
 		return
@@ -0,0 +1,432 @@
+package L
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Overflow policies of an Async writer, for when its queue is full.
+const (
+	// OverflowBlock blocks writes until there is room in the queue.
+	OverflowBlock = iota
+	// OverflowDropNewest drops the record being written.
+	OverflowDropNewest
+	// OverflowDropOldest drops the oldest queued record.
+	OverflowDropOldest
+	// OverflowSample queues only one in every AsyncOpts.Sample records
+	// while the queue is at least half full, and drops the record being
+	// written while it is full.
+	OverflowSample
+)
+
+// ErrClosed is returned by writes to a closed writer.
+var ErrClosed = errors.New("L: writer closed")
+
+// AsyncOpts are the options of an Async writer.
+type AsyncOpts struct {
+	// Size is the number of records the queue holds, 1024 if zero.
+	Size int
+	// Overflow is one of OverflowBlock, OverflowDropNewest,
+	// OverflowDropOldest or OverflowSample.
+	Overflow int
+	// Sample is the sampling rate of OverflowSample, 10 if zero.
+	Sample int
+	// ReportInterval is the interval at which the number of records
+	// dropped since the last report is reported, if any were dropped,
+	// 10 seconds if zero.  If negative, drops are reported only on
+	// Close.
+	ReportInterval time.Duration
+	// Report reports 'dropped' records to 'w', the underlying writer,
+	// between records.  If nil, a line {"Ldropped":dropped} is written.
+	Report func(w io.Writer, dropped int64)
+}
+
+// Async is an io.WriteCloser, created by AsyncWriter, which queues the
+// records written to it and writes them to an underlying writer from a
+// separate goroutine, so that loggers are not blocked by a slow writer.
+// Each call to Write is a record.  Errors in writing to the underlying
+// writer are returned by the next call to Write, Flush or Close.
+//
+// Until they are closed, Async writers are flushed by Obj.Fatal and EFatal
+// before the program exits.
+//
+// An Async writer is safe for concurrent use.
+type Async struct {
+	w    io.Writer
+	opts AsyncOpts
+	key  *asyncKey // if in asyncWriters
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	q       [][]byte
+	head, n int
+	free    [][]byte
+	writing bool
+	report  bool
+	closed  bool
+	sample  int
+	dropped int64
+	total   int64
+	err     error
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// AsyncWriter creates an Async writer to 'w' with options 'opts', which
+// may be nil.
+func AsyncWriter(w io.Writer, opts *AsyncOpts) *Async {
+	a := &Async{w: w}
+	if opts != nil {
+		a.opts = *opts
+	}
+	if a.opts.Size <= 0 {
+		a.opts.Size = 1024
+	}
+	if a.opts.Sample <= 0 {
+		a.opts.Sample = 10
+	}
+	if a.opts.ReportInterval == 0 {
+		a.opts.ReportInterval = 10 * time.Second
+	}
+	if a.opts.Report == nil {
+		a.opts.Report = reportDropped
+	}
+	a.cond = sync.NewCond(&a.mu)
+	a.q = make([][]byte, a.opts.Size)
+	a.stop = make(chan struct{})
+	a.done = make(chan struct{})
+	go a.loop()
+	if a.opts.ReportInterval > 0 {
+		go a.tick()
+	}
+	flushers.add(a)
+	return a
+}
+
+func reportDropped(w io.Writer, dropped int64) {
+	b := make([]byte, 0, 32)
+	b = append(b, `{"Ldropped":`...)
+	b = strconv.AppendInt(b, dropped, 10)
+	b = append(b, "}\n"...)
+	w.Write(b)
+}
+
+// Write queues a copy of 'p' according to the overflow policy.  It
+// returns any error in writing a previous record, even if 'p' is queued.
+func (a *Async) Write(p []byte) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return 0, ErrClosed
+	}
+	err := a.err
+	a.err = nil
+	size := len(a.q)
+	switch a.opts.Overflow {
+	case OverflowBlock:
+		for a.n == size && !a.closed {
+			a.cond.Wait()
+		}
+		if a.closed {
+			return 0, ErrClosed
+		}
+	case OverflowDropNewest:
+		if a.n == size {
+			a.drop()
+			return len(p), err
+		}
+	case OverflowDropOldest:
+		if a.n == size {
+			if len(a.free) < len(a.q) {
+				a.free = append(a.free, a.q[a.head][:0])
+			}
+			a.q[a.head] = nil
+			a.head = (a.head + 1) % size
+			a.n--
+			a.drop()
+		}
+	case OverflowSample:
+		if a.n == size {
+			a.drop()
+			return len(p), err
+		}
+		if 2*a.n >= size {
+			a.sample++
+			if a.sample%a.opts.Sample != 0 {
+				a.drop()
+				return len(p), err
+			}
+		}
+	}
+	var b []byte
+	if k := len(a.free); k > 0 {
+		b = a.free[k-1]
+		a.free = a.free[:k-1]
+	}
+	a.q[(a.head+a.n)%size] = append(b, p...)
+	a.n++
+	a.cond.Broadcast()
+	return len(p), err
+}
+
+func (a *Async) drop() {
+	a.dropped++
+	a.total++
+}
+
+// Dropped returns the total number of records dropped by 'a'.
+func (a *Async) Dropped() int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.total
+}
+
+// loop writes the queued records and reports drops.
+func (a *Async) loop() {
+	defer close(a.done)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for {
+		for a.n == 0 && !a.report && !a.closed {
+			a.cond.Wait()
+		}
+		if a.report || (a.closed && a.n == 0) {
+			a.report = false
+			if d := a.dropped; d > 0 {
+				a.dropped = 0
+				a.writing = true
+				a.mu.Unlock()
+				a.opts.Report(a.w, d)
+				a.mu.Lock()
+				a.writing = false
+			}
+		}
+		if a.n == 0 {
+			if a.closed {
+				a.cond.Broadcast()
+				return
+			}
+			a.cond.Broadcast()
+			continue
+		}
+		b := a.q[a.head]
+		a.q[a.head] = nil
+		a.head = (a.head + 1) % len(a.q)
+		a.n--
+		a.writing = true
+		a.cond.Broadcast()
+		a.mu.Unlock()
+		_, err := a.w.Write(b)
+		a.mu.Lock()
+		a.writing = false
+		if err != nil {
+			a.err = err
+		}
+		if len(a.free) < len(a.q) {
+			a.free = append(a.free, b[:0])
+		}
+		if a.n == 0 {
+			a.cond.Broadcast()
+		}
+	}
+}
+
+func (a *Async) tick() {
+	t := time.NewTicker(a.opts.ReportInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-t.C:
+			a.mu.Lock()
+			if a.dropped > 0 {
+				a.report = true
+				a.cond.Broadcast()
+			}
+			a.mu.Unlock()
+		}
+	}
+}
+
+// Flush waits until the queued records have been written or 'ctx' is
+// done.  It returns ctx.Err() in the latter case, and otherwise any
+// error in writing.
+func (a *Async) Flush(ctx context.Context) error {
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			a.mu.Lock()
+			a.cond.Broadcast()
+			a.mu.Unlock()
+		case <-stop:
+		}
+	}()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for (a.n > 0 || a.writing) && ctx.Err() == nil {
+		a.cond.Wait()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	err := a.err
+	a.err = nil
+	return err
+}
+
+// Close writes the queued records and any report of drops, and stops
+// 'a'.  It does not close the underlying writer.
+func (a *Async) Close() error {
+	if a.key != nil {
+		asyncWriters.Lock()
+		if asyncWriters.m[*a.key] == a {
+			delete(asyncWriters.m, *a.key)
+		}
+		asyncWriters.Unlock()
+	}
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return ErrClosed
+	}
+	a.closed = true
+	a.cond.Broadcast()
+	a.mu.Unlock()
+	close(a.stop)
+	<-a.done
+	flushers.remove(a)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	err := a.err
+	a.err = nil
+	return err
+}
+
+// flushers are the open Async writers, flushed before exiting.
+var flushers = &flusherSet{m: map[*Async]struct{}{}}
+
+type flusherSet struct {
+	mu sync.Mutex
+	m  map[*Async]struct{}
+}
+
+func (s *flusherSet) add(a *Async) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m[a] = struct{}{}
+}
+
+func (s *flusherSet) remove(a *Async) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.m, a)
+}
+
+// FlushTimeout bounds the time Obj.Fatal and EFatal wait for Async
+// writers to be flushed.
+var FlushTimeout = 5 * time.Second
+
+// Flush flushes all Async writers which are not closed, returning the
+// first error.
+func Flush(ctx context.Context) error {
+	flushers.mu.Lock()
+	as := make([]*Async, 0, len(flushers.m))
+	for a := range flushers.m {
+		as = append(as, a)
+	}
+	flushers.mu.Unlock()
+	var res error
+	for _, a := range as {
+		if err := a.Flush(ctx); err != nil && res == nil {
+			res = err
+		}
+	}
+	return res
+}
+
+func flushBeforeExit() {
+	ctx, cancel := context.WithTimeout(context.Background(), FlushTimeout)
+	defer cancel()
+	Flush(ctx)
+}
+
+// asyncWriters are the open Async writers created by the "async" writer,
+// by underlying writer and options, so that applying a configuration
+// again does not start another.
+var asyncWriters = struct {
+	sync.Mutex
+	m map[asyncKey]*Async
+}{m: map[asyncKey]*Async{}}
+
+// asyncKey is the key of an Async writer in asyncWriters.
+type asyncKey struct {
+	w        io.Writer
+	size     int
+	overflow int
+	sample   int
+	interval time.Duration
+}
+
+// The "async" writer is an Async writer to the writer of the Spec "w".
+// Uses with the same options and a writer which is the same, as those of
+// the "file" and "net" writers are, share an Async.
+func init() {
+	RegisterWriter("async", func(p json.RawMessage) (io.Writer, error) {
+		var ap struct {
+			W              *Spec  `json:"w"`
+			Size           int    `json:"size"`
+			Overflow       string `json:"overflow"`
+			Sample         int    `json:"sample"`
+			ReportInterval string `json:"reportInterval"`
+		}
+		if err := DecodeParams(p, &ap); err != nil {
+			return nil, err
+		}
+		if ap.W == nil {
+			return nil, fmt.Errorf("no w")
+		}
+		opts := &AsyncOpts{Size: ap.Size, Sample: ap.Sample}
+		switch ap.Overflow {
+		case "", "block":
+		case "dropNewest":
+			opts.Overflow = OverflowDropNewest
+		case "dropOldest":
+			opts.Overflow = OverflowDropOldest
+		case "sample":
+			opts.Overflow = OverflowSample
+		default:
+			return nil, fmt.Errorf("unknown overflow %q", ap.Overflow)
+		}
+		var err error
+		if opts.ReportInterval, err = parseDuration(ap.ReportInterval); err != nil {
+			return nil, err
+		}
+		w, err := NewWriter(ap.W)
+		if err != nil {
+			return nil, err
+		}
+		if t := reflect.TypeOf(w); t == nil || !t.Comparable() {
+			return AsyncWriter(w, opts), nil
+		}
+		key := asyncKey{w, opts.Size, opts.Overflow, opts.Sample, opts.ReportInterval}
+		asyncWriters.Lock()
+		defer asyncWriters.Unlock()
+		if a := asyncWriters.m[key]; a != nil {
+			return a, nil
+		}
+		a := AsyncWriter(w, opts)
+		a.key = &key
+		asyncWriters.m[key] = a
+		return a, nil
+	})
+}
@@ -0,0 +1,177 @@
+package L_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scott-cotton/L"
+)
+
+// gateWriter is a writer which blocks until its gate is opened.
+type gateWriter struct {
+	gate    chan struct{}
+	started chan struct{}
+	once    sync.Once
+	mu      sync.Mutex
+	buf     bytes.Buffer
+}
+
+func newGateWriter() *gateWriter {
+	return &gateWriter{gate: make(chan struct{}), started: make(chan struct{})}
+}
+
+func (w *gateWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.started) })
+	<-w.gate
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *gateWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+func TestAsyncOverflow(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		overflow int
+		want     string
+		dropped  int64
+	}{
+		{"dropNewest", L.OverflowDropNewest, "0\n1\n2\n", 7},
+		{"dropOldest", L.OverflowDropOldest, "0\n8\n9\n", 7},
+		{"sample", L.OverflowSample, "0\n1\n5\n", 7},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			gw := newGateWriter()
+			a := L.AsyncWriter(gw, &L.AsyncOpts{
+				Size:           2,
+				Overflow:       tc.overflow,
+				Sample:         4,
+				ReportInterval: -1,
+			})
+			a.Write([]byte("0\n"))
+			<-gw.started
+			for i := 1; i < 10; i++ {
+				a.Write([]byte{byte('0' + i), '\n'})
+			}
+			if got := a.Dropped(); got != tc.dropped {
+				t.Errorf("dropped %d want %d", got, tc.dropped)
+			}
+			close(gw.gate)
+			if err := a.Close(); err != nil {
+				t.Fatal(err)
+			}
+			want := tc.want + `{"Ldropped":7}` + "\n"
+			if got := gw.String(); got != want {
+				t.Errorf("got %q want %q", got, want)
+			}
+			if _, err := a.Write(nil); !errors.Is(err, L.ErrClosed) {
+				t.Errorf("write after close: %v", err)
+			}
+		})
+	}
+}
+
+func TestAsyncLogger(t *testing.T) {
+	gw := newGateWriter()
+	a := L.AsyncWriter(gw, &L.AsyncOpts{Size: 100})
+	defer a.Close()
+	l := L.New(&L.Config{W: a, F: L.JSONFmter(), E: L.EPanic})
+	defer l.Close()
+	for i := 0; i < 10; i++ {
+		l.Dict().Field("i", i).Log()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := L.Flush(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("flush of stalled writer: %v", err)
+	}
+	close(gw.gate)
+	if err := a.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(gw.String(), "\n"); got != 10 {
+		t.Errorf("got %d lines: %q", got, gw.String())
+	}
+}
+
+func TestAsyncReport(t *testing.T) {
+	gw := newGateWriter()
+	var mu sync.Mutex
+	var reported int64
+	a := L.AsyncWriter(gw, &L.AsyncOpts{
+		Size:           1,
+		Overflow:       L.OverflowDropNewest,
+		ReportInterval: time.Millisecond,
+		Report: func(_ io.Writer, n int64) {
+			mu.Lock()
+			defer mu.Unlock()
+			reported += n
+		},
+	})
+	for i := 0; i < 5; i++ {
+		a.Write([]byte("x\n"))
+	}
+	close(gw.gate)
+	var got int64
+	for i := 0; i < 1000 && got != a.Dropped(); i++ {
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		got = reported
+		mu.Unlock()
+	}
+	if got == 0 || got != a.Dropped() {
+		t.Errorf("reported %d of %d dropped", got, a.Dropped())
+	}
+	a.Close()
+}
+
+func TestAsyncSpec(t *testing.T) {
+	registryW.Reset()
+	spec := &L.Spec{Name: "async", Params: []byte(`{
+		"w": {"name": "test-buffer"},
+		"size": 4,
+		"overflow": "dropOldest",
+		"reportInterval": "-1s"
+	}`)}
+	w, err := L.NewWriter(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := w.(*L.Async)
+	if _, err := a.Write([]byte("a\n")); err != nil {
+		t.Fatal(err)
+	}
+	if w, _ := L.NewWriter(spec); w != a {
+		t.Errorf("the same spec created another Async")
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	w, err = L.NewWriter(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w == a {
+		t.Errorf("a closed Async was reused")
+	}
+	w.(*L.Async).Close()
+	if got := registryW.String(); got != "a\n" {
+		t.Errorf("got %q", got)
+	}
+	for _, bad := range []string{`{}`, `{"w": {"name": "stderr"}, "overflow": "nope"}`, `{"w": {"name": "nope"}}`} {
+		if _, err := L.NewWriter(&L.Spec{Name: "async", Params: []byte(bad)}); err == nil {
+			t.Errorf("%s: no error", bad)
+		}
+	}
+}
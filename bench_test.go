@@ -64,3 +64,136 @@ func BenchmarkConstructDict(b *testing.B) {
 			Field("key3", "hello susan")
 	}
 }
+
+type benchStruct struct {
+	Key0 int    `L:"key0"`
+	Key2 bool   `L:"key2"`
+	Key3 string `L:"key3"`
+}
+
+func BenchmarkFieldStruct(b *testing.B) {
+	b.StopTimer()
+	L := L.New(&L.Config{
+		Labels: map[string]int{},
+		W:      io.Discard,
+		F:      L.JSONFmter(),
+		E:      L.EPanic,
+	})
+	v := &benchStruct{Key0: 22, Key3: "hello susan"}
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		L.Dict().Field("v", v)
+	}
+}
+
+func BenchmarkCaller(b *testing.B) {
+	b.StopTimer()
+	L := L.New(&L.Config{
+		Labels: map[string]int{},
+		W:      io.Discard,
+		F:      L.JSONFmter(),
+		E:      L.EPanic,
+		Post:   []L.Middleware{L.Caller()},
+	})
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		L.Dict().Field("key0", 22).Log()
+	}
+}
+
+// fmtLine is the record formatted by the BenchmarkFmt benchmarks.
+// JSONFmter only writes it, so BenchmarkFmtJSON is the floor for the
+// other Fmters.
+var fmtLine = []byte(`{"msg":"request served","time":"2022-01-02T03:04:05Z","http":{"method":"GET","path":"/a/b","status":200},"dur":0.0123,"user":"susan \"s\"","ok":true}`)
+
+func benchmarkFmt(b *testing.B, f L.Fmter) {
+	b.ReportAllocs()
+	d := make([]byte, len(fmtLine), len(fmtLine)+1)
+	copy(d, fmtLine)
+	for i := 0; i < b.N; i++ {
+		f.Fmt(io.Discard, d)
+	}
+}
+
+func BenchmarkFmtJSON(b *testing.B) {
+	benchmarkFmt(b, L.JSONFmter())
+}
+
+func BenchmarkFmtTable(b *testing.B) {
+	benchmarkFmt(b, &L.TableFmter{Fields: []string{"time", "msg", "http.status", "user"}})
+}
+
+func BenchmarkFmtLogfmt(b *testing.B) {
+	benchmarkFmt(b, &L.LogfmtFmter{First: []string{"time", "msg"}})
+}
+
+func BenchmarkFmtConsole(b *testing.B) {
+	benchmarkFmt(b, &L.ConsoleFmter{})
+}
+
+func BenchmarkFmtSyslog(b *testing.B) {
+	benchmarkFmt(b, &L.SyslogFmter{StructuredData: true})
+}
+
+func BenchmarkFmtGELF(b *testing.B) {
+	benchmarkFmt(b, &L.GELFFmter{})
+}
+
+func BenchmarkFmtECS(b *testing.B) {
+	benchmarkFmt(b, &L.ECSFmter{})
+}
+
+func BenchmarkFmtOTLP(b *testing.B) {
+	benchmarkFmt(b, &L.OTLPFmter{})
+}
+
+func BenchmarkFmtTemplate(b *testing.B) {
+	benchmarkFmt(b, L.MustTemplateFmter(`{{.time}} {{.msg}} {{.http.status}}`))
+}
+
+// TestFmtAllocs checks that the Fmters which stream records with a
+// Scanner do not allocate.  TemplateFmter is exempt: text/template
+// executes templates on the values of records decoded by encoding/json,
+// which allocates.
+func TestFmtAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("pooled state is dropped under the race detector")
+	}
+	var recs [][]byte
+	for _, rec := range []string{
+		string(fmtLine),
+		`{"time":"2022-01-02T03:04:05Z","Lpkg":"p","msg":"a\nb","lvl":3,"Lstack":[{"func":"f","file":"f.go","line":1}],"ids":[1,2],"q":"x=y"}`,
+	} {
+		// with room for the newline of JSONFmter.
+		d := make([]byte, len(rec), len(rec)+1)
+		copy(d, rec)
+		recs = append(recs, d)
+	}
+	for _, tc := range []struct {
+		name string
+		f    L.Fmter
+	}{
+		{"json", L.JSONFmter()},
+		{"table", &L.TableFmter{Fields: []string{"time", "msg", "http.status", "user"}, AutoWidth: true}},
+		{"csv", &L.TableFmter{Fields: []string{"time", "msg", "http"}, Mode: L.TableCSV}},
+		{"logfmt", &L.LogfmtFmter{First: []string{"time", "msg"}}},
+		{"console", &L.ConsoleFmter{}},
+		{"consoleColor", &L.ConsoleFmter{Color: L.ColorAlways, LevelKey: "lvl", TimeFormat: "15:04:05"}},
+		{"syslog", &L.SyslogFmter{Hostname: "h", AppName: "a", ProcID: "1"}},
+		{"syslogSD", &L.SyslogFmter{Hostname: "h", AppName: "a", ProcID: "1", StructuredData: true, LevelKey: "lvl"}},
+		{"gelf", &L.GELFFmter{Host: "h", LevelKey: "lvl", Levels: []L.SyslogLevel{{Min: 3, Severity: L.SevErr}}}},
+		{"ecs", &L.ECSFmter{Host: "h", LevelKey: "lvl", Levels: []L.ECSLevel{{Min: 3, Level: "error"}}, Namespace: "app"}},
+		{"otlp", &L.OTLPFmter{SeverityKey: "lvl", Severities: []L.OTLPSeverity{{Min: 3, Number: 17, Text: "ERROR"}}, Resource: map[string]string{"service.name": "s", "host.name": "h"}}},
+	} {
+		for _, rec := range recs {
+			n := testing.AllocsPerRun(100, func() {
+				if err := tc.f.Fmt(io.Discard, rec); err != nil {
+					t.Fatal(err)
+				}
+			})
+			if n != 0 {
+				t.Errorf("%s: %v allocations formatting %s", tc.name, n, rec)
+			}
+		}
+	}
+}
@@ -14,8 +14,14 @@ const usage = `Lctl <name|url> <cmd>
 <cmd> can be one of
 - loggers
 	retrieve all label information about loggers listening on <url>.
+- registered
+	retrieve the names of the fmters, writers and middleware which
+	may be used in an apply.
 - apply <input>
 	<input> can be a file or '-' for standard input
+- recent [<input>]
+	retrieve recent records kept in ring sinks, selected by the query
+	in <input>, a file or '-' for standard input, or all of them.
 `
 
 var logger = L.New(&L.Config{
@@ -24,7 +30,7 @@ var logger = L.New(&L.Config{
 	W:      os.Stderr,
 	E:      L.EPanic,
 	F: &L.TableFmter{
-		Fields: []string{"msg", "time", "method", "Lerr"},
+		Fields: []string{"msg", "time", "method", "Lerr.msg"},
 		Sep:    " ",
 	},
 })
@@ -58,6 +64,16 @@ func main() {
 		if err := jenc.Encode(res); err != nil {
 			wo.Err(err).Fatal()
 		}
+	case "registered":
+		res, err := client.Registered()
+		if err != nil {
+			wo.Err(err).Fatal()
+		}
+		jenc := json.NewEncoder(os.Stdout)
+		jenc.SetIndent("", "  ")
+		if err := jenc.Encode(res); err != nil {
+			wo.Err(err).Fatal()
+		}
 	case "apply":
 		if len(args) == 1 {
 			wo.Errf("no args specified, usage:\n%s", usage).Fatal()
@@ -86,6 +102,33 @@ func main() {
 			wo.Err(err).Fatal()
 		}
 
+	case "recent":
+		var params rpc.RecentParams
+		if len(args) > 1 {
+			fname := args[1]
+			r := os.Stdin
+			if fname != "-" {
+				r, err = os.Open(fname)
+				if err != nil {
+					wo.Err(err).Fatal()
+				}
+				defer r.Close()
+			}
+			if err := json.NewDecoder(r).Decode(&params); err != nil {
+				wo.Err(err).Fatal()
+			}
+		}
+		res, err := client.Recent(&params)
+		if err != nil {
+			wo.Err(err).Fatal()
+		}
+		jenc := json.NewEncoder(os.Stdout)
+		for _, rec := range *res {
+			if err := jenc.Encode(rec); err != nil {
+				wo.Err(err).Fatal()
+			}
+		}
+
 	default:
 		wo.Errf("unknown method %q", args[0]).Fatal()
 	}
@@ -1,6 +1,7 @@
 package L
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"runtime"
@@ -28,10 +29,51 @@ type Config struct {
 	// F is a Fmter for the logger.
 	F Fmter `json:"-"`
 
-	// E is a handler for any errors which occur during
-	// formatting.
+	// E is a handler for errors in logging: errors in constructing
+	// objects and, as *SinkErrors, errors in formatting and writing them
+	// and changes of state of writers.  NewConfig sets it to EDefault,
+	// which panics only on the former.
 	E func(*Config, error) `json:"-"`
 
+	// Sinks are additional destinations for the objects
+	// of the logger.
+	Sinks []Sink `json:"sinks,omitempty"`
+
+	// PreSpec, PostSpec, WSpec and FSpec describe Pre,
+	// Post, W and F by the names of registered factories.
+	// They are used by Resolve and shown in ConfigTree.
+	PreSpec  []Spec `json:"pre,omitempty"`
+	PostSpec []Spec `json:"post,omitempty"`
+	WSpec    *Spec  `json:"w,omitempty"`
+	FSpec    *Spec  `json:"f,omitempty"`
+
+	// NoValidate, if set to true, disables the validation
+	// of the json constructed in the Objs of a logger.  It
+	// is a pointer so that Apply may turn validation on or
+	// off, and leaves it untouched if nil.
+	NoValidate *bool `json:"noValidate,omitempty"`
+
+	// EscapeHTML, if set to true, causes '<', '>' and '&'
+	// to be escaped in strings, as by json.Marshal.  Like
+	// NoValidate, it is a pointer for Apply.
+	EscapeHTML *bool `json:"escapeHTML,omitempty"`
+
+	// FloatFormat is the format of floats, one of "e", "f" or "g" as
+	// for strconv.FormatFloat, or FloatJSON.  If empty or FloatJSON,
+	// floats are formatted as by encoding/json.
+	FloatFormat string `json:"floatFormat,omitempty"`
+
+	// FloatPrec is the precision of floats with a FloatFormat, as for
+	// strconv.FormatFloat.  If zero or negative, the smallest
+	// precision representing the value exactly is used.  Apply keeps
+	// the precision if zero, and resets it if negative.
+	FloatPrec int `json:"floatPrec,omitempty"`
+
+	// NonFinite is the policy for NaN and infinite floats, one of
+	// NonFiniteString, NonFiniteNull or NonFiniteError.  If empty,
+	// NonFiniteString is used.
+	NonFinite string `json:"nonFinite,omitempty"`
+
 	pkg string
 }
 
@@ -54,7 +96,7 @@ func NewConfig(labels ...string) *Config {
 	c := &Config{
 		Labels: map[string]int{},
 		W:      os.Stderr,
-		E:      EPanic,
+		E:      EDefault,
 		pkg:    pkg,
 	}
 	for _, lbl := range labels {
@@ -63,12 +105,33 @@ func NewConfig(labels ...string) *Config {
 	return c
 }
 
+// objOpts are the settings of a Config which determine the encoding
+// of Objs, captured when an Obj is created.
+type objOpts struct {
+	noValidate bool
+	escapeHTML bool
+	float      floatOpts
+}
+
+func (c *Config) objOpts() objOpts {
+	return objOpts{
+		noValidate: c.NoValidate != nil && *c.NoValidate,
+		escapeHTML: c.EscapeHTML != nil && *c.EscapeHTML,
+		float:      c.floatOpts(),
+	}
+}
+
 // Clone clones the configuration c.
 func (c *Config) Clone() *Config {
 	res := &Config{}
 	*res = *c
 	res.Pre = append([]Middleware{}, c.Pre...)
 	res.Post = append([]Middleware{}, c.Post...)
+	res.PreSpec = append([]Spec(nil), c.PreSpec...)
+	res.PostSpec = append([]Spec(nil), c.PostSpec...)
+	res.Sinks = cloneSinks(c.Sinks)
+	res.NoValidate = cloneBool(c.NoValidate)
+	res.EscapeHTML = cloneBool(c.EscapeHTML)
 	res.Labels = make(map[string]int, len(c.Labels))
 	res.pkg = c.pkg
 	for k, v := range c.Labels {
@@ -77,6 +140,57 @@ func (c *Config) Clone() *Config {
 	return res
 }
 
+func cloneBool(b *bool) *bool {
+	if b == nil {
+		return nil
+	}
+	v := *b
+	return &v
+}
+
+// Resolve sets Pre, Post, W and F, and those of the Sinks, from their
+// Specs, where the Specs are not nil.  Resolve returns an error and
+// leaves 'c' unchanged if a Spec names an unregistered factory or a
+// factory fails.
+func (c *Config) Resolve() error {
+	res := c.Clone()
+	if err := res.resolve(); err != nil {
+		return err
+	}
+	for i := range res.Sinks {
+		if err := res.Sinks[i].resolve(); err != nil {
+			return fmt.Errorf("sink %q: %w", res.Sinks[i].Name, err)
+		}
+	}
+	*c = *res
+	return nil
+}
+
+func (c *Config) resolve() error {
+	var err error
+	if c.PreSpec != nil {
+		if c.Pre, err = newMiddlewares(c.PreSpec); err != nil {
+			return err
+		}
+	}
+	if c.PostSpec != nil {
+		if c.Post, err = newMiddlewares(c.PostSpec); err != nil {
+			return err
+		}
+	}
+	if c.WSpec != nil {
+		if c.W, err = NewWriter(c.WSpec); err != nil {
+			return err
+		}
+	}
+	if c.FSpec != nil {
+		if c.F, err = NewFmter(c.FSpec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Unlocalize prefixes label with the c's package if
 // label starts with '.'.
 func (c *Config) Unlocalize(label string) string {
@@ -22,5 +22,5 @@ func Example_configE() {
 	fmt.Printf("%s\n", eW.String())
 
 	// Output:
-	// {"LE":"invalid character ',' after top-level value"}
+	// {"LE":"invalid character ',' after top-level value at offset 7"}
 }
@@ -0,0 +1,449 @@
+package L
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Color modes for ConsoleFmter.Color.
+const (
+	// ColorAuto enables color when the writer is a terminal and the
+	// NO_COLOR environment variable is not set.
+	ColorAuto = iota
+	ColorAlways
+	ColorNever
+)
+
+// LevelColor associates an ANSI color with the values of a level label
+// of at least Min.  Color is an SGR parameter such as "31" for red.
+type LevelColor struct {
+	Min   int    `json:"min,omitempty"`
+	Color string `json:"color,omitempty"`
+}
+
+// DefaultLevelColors are the LevelColors used by ConsoleFmter when its
+// LevelColors is nil.
+var DefaultLevelColors = []LevelColor{
+	{Min: 1, Color: "32"},
+	{Min: 2, Color: "33"},
+	{Min: 3, Color: "31"},
+	{Min: 4, Color: "1;31"},
+}
+
+// ConsoleFmter is a Fmter for reading logs on a terminal.  Each dict is
+// written on a line of the form
+//
+//	<time> <pkg> <msg>    key=value key=value ...
+//
+// where the leading columns are taken from the fields with keys TimeKey,
+// PkgKey and MsgKey and are omitted when absent.  The remaining fields
+// follow in order, aligned to start at column Align.  Nested dicts
+// are written as {key=value ...} and arrays as [value ...].  Values with
+// several lines, and arrays of dicts such as stacks, are written on the
+// following lines, indented.  Objects other than dicts are written as
+// json.
+//
+// If LevelKey is set, the value of the field with that key, such as a
+// label added by the Label middleware, selects the color of the message
+// from LevelColors: that of the last entry whose Min is at most the value.
+type ConsoleFmter struct {
+	// TimeKey, PkgKey and MsgKey are the keys of the leading columns,
+	// "time", "Lpkg" and "msg" if empty.
+	TimeKey string `json:"timeKey,omitempty"`
+	PkgKey  string `json:"pkgKey,omitempty"`
+	MsgKey  string `json:"msgKey,omitempty"`
+	// TimeFormat, if set, reformats RFC3339 times in the time column
+	// with time.Time.Format.
+	TimeFormat string `json:"timeFormat,omitempty"`
+	// Align is the column at which the trailing fields start when
+	// the leading columns are shorter, 60 if zero.
+	Align int `json:"align,omitempty"`
+	// LevelKey is the key of the field determining the color of the
+	// message.
+	LevelKey string `json:"levelKey,omitempty"`
+	// LevelColors are the colors for LevelKey, DefaultLevelColors if
+	// nil.
+	LevelColors []LevelColor `json:"levelColors,omitempty"`
+	// Color is one of ColorAuto, ColorAlways or ColorNever.
+	Color int `json:"color,omitempty"`
+}
+
+const (
+	ansiDim   = "2"
+	ansiBold  = "1"
+	ansiCyan  = "36"
+	ansiBlue  = "34"
+	ansiReset = "\x1b[0m"
+)
+
+// consoleState holds the state and buffers of a call to
+// ConsoleFmter.Fmt.
+type consoleState struct {
+	c     *ConsoleFmter
+	d     []byte
+	color bool
+	sc    Scanner
+	buf   []byte
+	// tmp holds unquoted strings.
+	tmp []byte
+	// escapes counts the bytes of color escapes in buf.
+	escapes int
+	// rest holds the trailing fields, and more the multi-line ones,
+	// written after the line.
+	rest, more []member
+}
+
+var consolePool = sync.Pool{
+	New: func() any { return &consoleState{} },
+}
+
+func (c *ConsoleFmter) Fmt(w io.Writer, d []byte) error {
+	s := consolePool.Get().(*consoleState)
+	defer consolePool.Put(s)
+	s.c, s.d, s.color = c, d, c.useColor(w)
+	s.buf, s.escapes = s.buf[:0], 0
+	s.rest, s.more = s.rest[:0], s.more[:0]
+	i := skipSpace(d, 0)
+	if i == len(d) || d[i] != '{' {
+		s.buf = append(s.buf, d...)
+		s.buf = append(s.buf, '\n')
+	} else {
+		s.line(i)
+	}
+	_, err := w.Write(s.buf)
+	s.c, s.d = nil, nil
+	return err
+}
+
+func (c *ConsoleFmter) useColor(w io.Writer) bool {
+	switch c.Color {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	if tty, ok := terminals.Load(f); ok {
+		return tty.(bool)
+	}
+	st, err := f.Stat()
+	tty := err == nil && st.Mode()&os.ModeCharDevice != 0
+	terminals.Store(f, tty)
+	return tty
+}
+
+// terminals records whether the files written by ConsoleFmters are
+// terminals, so that they are checked once.
+var terminals sync.Map
+
+func orDefault(s, dflt string) string {
+	if s == "" {
+		return dflt
+	}
+	return s
+}
+
+// line writes the dict at s.d[i].
+func (s *consoleState) line(i int) {
+	c := s.c
+	var (
+		keys = [3]string{
+			orDefault(c.TimeKey, "time"),
+			orDefault(c.PkgKey, "Lpkg"),
+			orDefault(c.MsgKey, "msg"),
+		}
+		// lead holds the fields of 'keys', with ve zero if absent.
+		lead [3]member
+	)
+	d := s.d
+	sc := &s.sc
+	sc.resetAt(d, i)
+	for m, ok := sc.field(); ok; m, ok = sc.field() {
+		j := 0
+		for j < len(keys) && !(lead[j].ve == 0 && keyIs(d[m.ks:m.ke], keys[j])) {
+			j++
+		}
+		if j < len(keys) {
+			lead[j] = m
+		} else {
+			s.rest = append(s.rest, m)
+		}
+	}
+	start := len(s.buf)
+	if m := lead[0]; m.ve != 0 {
+		s.paint(ansiDim, s.timeText(d[m.vs:m.ve]))
+		s.buf = append(s.buf, ' ')
+	}
+	if m := lead[1]; m.ve != 0 {
+		s.paint(ansiCyan, s.text(d[m.vs:m.ve]))
+		s.buf = append(s.buf, ' ')
+	}
+	if m := lead[2]; m.ve != 0 {
+		msg := s.text(d[m.vs:m.ve])
+		if bytes.IndexByte(msg, '\n') != -1 {
+			s.rest = append(s.rest, member{})
+			copy(s.rest[1:], s.rest)
+			s.rest[0] = m
+			msg = nil
+		}
+		s.paint(s.levelColor(), msg)
+	}
+	align := c.Align
+	if align == 0 {
+		align = 60
+	}
+	// the width, ignoring color escapes.
+	w := utf8.RuneCount(s.buf[start:]) - s.escapes
+	for ; len(s.rest) > 0 && w < align-1; w++ {
+		s.buf = append(s.buf, ' ')
+	}
+	for _, m := range s.rest {
+		if s.multiLine(m.vs, m.ve) {
+			s.more = append(s.more, m)
+			continue
+		}
+		s.buf = append(s.buf, ' ')
+		s.field(m)
+	}
+	trimRight(&s.buf)
+	s.buf = append(s.buf, '\n')
+	for _, m := range s.more {
+		s.buf = append(s.buf, "    "...)
+		s.key(m)
+		s.buf = append(s.buf, '\n')
+		s.block(m.vs, "        ")
+	}
+}
+
+func trimRight(b *[]byte) {
+	d := *b
+	for len(d) > 0 && d[len(d)-1] == ' ' {
+		d = d[:len(d)-1]
+	}
+	*b = d
+}
+
+// paint appends 'text' with the SGR parameter 'sgr', if color is
+// enabled and 'sgr' is not empty.
+func (s *consoleState) paint(sgr string, text []byte) {
+	if !s.color || sgr == "" {
+		s.buf = append(s.buf, text...)
+		return
+	}
+	s.escapes += utf8.RuneCountInString(sgr) + 3 + len(ansiReset)
+	s.buf = append(s.buf, "\x1b["...)
+	s.buf = append(s.buf, sgr...)
+	s.buf = append(s.buf, 'm')
+	s.buf = append(s.buf, text...)
+	s.buf = append(s.buf, ansiReset...)
+}
+
+func (s *consoleState) levelColor() string {
+	c := s.c
+	if c.LevelKey == "" {
+		return ansiBold
+	}
+	colors := c.LevelColors
+	if colors == nil {
+		colors = DefaultLevelColors
+	}
+	d := s.d
+	for _, m := range s.rest {
+		if !keyIs(d[m.ks:m.ke], c.LevelKey) {
+			continue
+		}
+		v, err := strconv.Atoi(string(d[m.vs:m.ve]))
+		if err != nil {
+			break
+		}
+		res := ansiBold
+		for _, lc := range colors {
+			if lc.Min <= v {
+				res = lc.Color
+			}
+		}
+		return res
+	}
+	return ansiBold
+}
+
+func (s *consoleState) timeText(v []byte) []byte {
+	txt := s.text(v)
+	if s.c.TimeFormat == "" {
+		return txt
+	}
+	t, err := time.Parse(time.RFC3339Nano, string(txt))
+	if err != nil {
+		return txt
+	}
+	s.tmp = t.AppendFormat(s.tmp[:0], s.c.TimeFormat)
+	return s.tmp
+}
+
+// text returns the text of the value 'v', unquoted in s.tmp if it is a
+// string.
+func (s *consoleState) text(v []byte) []byte {
+	if len(v) > 0 && v[0] == '"' {
+		s.tmp = AppendUnquote(s.tmp[:0], v)
+		return s.tmp
+	}
+	return v
+}
+
+func (s *consoleState) key(m member) {
+	s.paint(ansiBlue, s.text(s.d[m.ks:m.ke]))
+	s.buf = append(s.buf, '=')
+}
+
+func (s *consoleState) field(m member) {
+	s.key(m)
+	s.value(m.vs)
+}
+
+// value appends the value at s.d[i] on a single line.
+func (s *consoleState) value(i int) {
+	d := s.d
+	switch d[i] {
+	case '"':
+		s.buf = appendText(s.buf, s.text(d[i:skipString(d, i)]))
+	case '{':
+		s.buf = append(s.buf, '{')
+		var sc Scanner
+		sc.resetAt(d, i)
+		for n := 0; ; n++ {
+			m, ok := sc.field()
+			if !ok {
+				break
+			}
+			if n > 0 {
+				s.buf = append(s.buf, ' ')
+			}
+			s.field(m)
+		}
+		s.buf = append(s.buf, '}')
+	case '[':
+		s.buf = append(s.buf, '[')
+		var sc Scanner
+		sc.resetAt(d, i)
+		for n := 0; ; n++ {
+			m, ok := sc.elem()
+			if !ok {
+				break
+			}
+			if n > 0 {
+				s.buf = append(s.buf, ' ')
+			}
+			s.value(m.vs)
+		}
+		s.buf = append(s.buf, ']')
+	default:
+		s.buf = append(s.buf, d[i:skipValue(d, i)]...)
+	}
+}
+
+// appendText appends 't', quoted if it is empty or contains spaces,
+// quotes, '=' or non-printable characters.
+func appendText(b, t []byte) []byte {
+	if len(t) == 0 {
+		return append(b, `""`...)
+	}
+	for i := 0; i < len(t); {
+		r, size := utf8.DecodeRune(t[i:])
+		if r <= ' ' || r == '"' || r == '=' || r == '\\' || !strconv.IsPrint(r) {
+			return appendQuote(b, t)
+		}
+		i += size
+	}
+	return append(b, t...)
+}
+
+// appendQuote appends 't' quoted as by strconv.Quote.
+func appendQuote(b, t []byte) []byte {
+	const hex = "0123456789abcdef"
+	var q [16]byte
+	b = append(b, '"')
+	for i := 0; i < len(t); {
+		r, size := utf8.DecodeRune(t[i:])
+		i += size
+		switch {
+		case r == utf8.RuneError && size == 1:
+			c := t[i-1]
+			b = append(b, '\\', 'x', hex[c>>4], hex[c&0xf])
+		case r == '"':
+			b = append(b, '\\', '"')
+		case r == '\'':
+			b = append(b, '\'')
+		default:
+			// as quoted in single quotes, without them.
+			e := strconv.AppendQuoteRune(q[:0], r)
+			b = append(b, e[1:len(e)-1]...)
+		}
+	}
+	return append(b, '"')
+}
+
+// multiLine returns whether the value from s.d[i] to s.d[j] is written
+// on several lines: a string containing a newline or an array of dicts.
+func (s *consoleState) multiLine(i, j int) bool {
+	d := s.d
+	switch d[i] {
+	case '"':
+		return bytes.IndexByte(s.text(d[i:j]), '\n') != -1
+	case '[':
+		var sc Scanner
+		sc.resetAt(d, i)
+		res := false
+		for {
+			m, ok := sc.elem()
+			if !ok {
+				return res
+			}
+			if d[m.vs] != '{' {
+				return false
+			}
+			res = true
+		}
+	}
+	return false
+}
+
+// block writes the multi-line value at s.d[i], each line prefixed by
+// 'indent'.
+func (s *consoleState) block(i int, indent string) {
+	d := s.d
+	if d[i] == '"' {
+		txt := bytes.TrimRight(s.text(d[i:skipString(d, i)]), "\n")
+		for {
+			ln := txt
+			j := bytes.IndexByte(txt, '\n')
+			if j != -1 {
+				ln = txt[:j]
+			}
+			s.buf = append(s.buf, indent...)
+			s.buf = append(s.buf, ln...)
+			s.buf = append(s.buf, '\n')
+			if j == -1 {
+				return
+			}
+			txt = txt[j+1:]
+		}
+	}
+	var sc Scanner
+	sc.resetAt(d, i)
+	for m, ok := sc.elem(); ok; m, ok = sc.elem() {
+		s.buf = append(s.buf, indent...)
+		s.value(m.vs)
+		s.buf = append(s.buf, '\n')
+	}
+}
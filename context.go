@@ -27,7 +27,6 @@ func FromContextWith(ctx context.Context, l Logger) *Obj {
 	if o == nil {
 		return nil
 	}
-	r := o.getRoot()
-	r.logger = l
-	return r
+	o.getRoot().logger = l
+	return o
 }
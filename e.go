@@ -1,23 +1,61 @@
 package L
 
-import "os"
+import (
+	"errors"
+	"os"
+)
 
 // EPanic is a Config.E that panics when there is an error.
 func EPanic(_ *Config, e error) {
 	panic(e)
 }
 
-// EFatal is a Config.E that calls ELog and then exits.
+// EDefault is the Config.E of NewConfig.  It writes *SinkErrors, errors
+// in formatting and writing objects and changes of state of writers, to
+// standard error in a dict with key '"LE"', since they may be transient,
+// as with a full disk or a closed pipe, and should not stop the program.
+// Other errors, such as those in constructing objects, are bugs in the
+// program, and EDefault panics on them as EPanic does.
+func EDefault(c *Config, e error) {
+	var se *SinkError
+	if errors.As(e, &se) {
+		eStderr(c, e)
+		return
+	}
+	panic(e)
+}
+
+// EFatal is a Config.E that calls ELog, flushes Async writers and then
+// exits.
 func EFatal(c *Config, e error) {
 	ELog(c, e)
+	flushBeforeExit()
 	os.Exit(7)
 }
 
 // ELog is a Config.E that safely logs the error 'e' in a dict with key '"LE"'.
+//
+// Errors in logging 'e', for example if it is an error of the writer of
+// 'c', are written to standard error rather than given to ELog again.
 func ELog(c *Config, e error) {
-	l := New(c)
+	cc := c.Clone()
+	cc.E = eStderr
+	for i := range cc.Sinks {
+		cc.Sinks[i].E = nil
+	}
+	l := New(cc)
 	defer l.Close()
 	ev := l.Dict()
 	ev.Field("LE", e.Error())
 	l.Log(ev)
 }
+
+// eStderr is a Config.E that writes the error 'e' to standard error in a
+// dict with key '"LE"'.
+func eStderr(_ *Config, e error) {
+	d := make([]byte, 0, 64)
+	d = append(d, `{"LE":`...)
+	d = appendString(d, e.Error(), false)
+	d = append(d, "}\n"...)
+	os.Stderr.Write(d)
+}
@@ -0,0 +1,248 @@
+package L
+
+import (
+	"io"
+	"time"
+)
+
+// ECSVersion is the version of the Elastic Common Schema written by
+// ECSFmter.
+const ECSVersion = "8.11.0"
+
+// ECSLevel associates an ECS log.level with the values of a level label
+// of at least Min.
+type ECSLevel struct {
+	Min   int    `json:"min,omitempty"`
+	Level string `json:"level,omitempty"`
+}
+
+// ECSFmter is a Fmter writing dicts as Elastic Common Schema documents,
+// one per line.
+//
+// The field with key MsgKey becomes the message, and the RFC3339 time
+// with key TimeKey becomes the @timestamp, which is otherwise the current
+// time.  Fields written by L are renamed: "Lpkg" to log.logger, "Lerr" to
+// error, with its msg as error.message, "Lcaller" to log.origin and
+// "Lstack" to error.stack_trace.  The fields ecs.version and host.hostname
+// are added.
+//
+// Other fields are placed under Namespace, if set, or at the top level,
+// with keys containing '.' expanded to nested dicts and merged with the
+// ECS fields.
+//
+// If LevelKey is set, the value of the field with that key, such as a
+// label added by the Label middleware, selects the log.level from Levels:
+// that of the last entry whose Min is at most the value.
+type ECSFmter struct {
+	// Host is the host.hostname, os.Hostname if empty.
+	Host string `json:"host,omitempty"`
+	// TimeKey, TimeLayout and MsgKey default to "time",
+	// time.RFC3339Nano and "msg".  TimeLayout should be that given to
+	// the TimeFormat middleware, if it is used.
+	TimeKey    string `json:"timeKey,omitempty"`
+	TimeLayout string `json:"timeLayout,omitempty"`
+	MsgKey     string `json:"msgKey,omitempty"`
+	// LevelKey and Levels determine the log.level.
+	LevelKey string     `json:"levelKey,omitempty"`
+	Levels   []ECSLevel `json:"levels,omitempty"`
+	// Namespace holds the fields which are not mapped, if set.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+func (f *ECSFmter) Fmt(w io.Writer, d []byte) error {
+	st := schemaPool.Get().(*schemaState)
+	defer schemaPool.Put(st)
+	r := st.split(d, schemaKeys{
+		timeKey:    f.TimeKey,
+		timeLayout: f.TimeLayout,
+		msgKey:     f.MsgKey,
+		levelKey:   f.LevelKey,
+	})
+	ts := r.time
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	st.keys, st.vals = st.keys[:0], st.vals[:0]
+	st.nodes = append(st.nodes[:0], ecsNode{})
+	const root = 0
+	v := len(st.vals)
+	st.vals = append(st.vals, '"')
+	st.vals = ts.UTC().AppendFormat(st.vals, time.RFC3339Nano)
+	st.vals = append(st.vals, '"')
+	st.ecsLeaf(root, st.ecsName("@timestamp"), v)
+	if r.msg != nil {
+		v := len(st.vals)
+		st.vals = st.appendTextString(st.vals, r.msg)
+		st.ecsLeaf(root, st.ecsName("message"), v)
+	}
+	if r.leveled {
+		for _, l := range f.Levels {
+			if l.Min <= r.level {
+				v := len(st.vals)
+				st.vals = appendString(st.vals, l.Level, false)
+				st.ecsLeaf(st.ecsChild(root, st.ecsName("log")), st.ecsName("level"), v)
+			}
+		}
+	}
+	if r.pkg != nil {
+		st.ecsSet(st.ecsChild(root, st.ecsName("log")), st.ecsName("logger"), r.pkg)
+	}
+	if r.caller != nil {
+		origin := st.ecsChild(st.ecsChild(root, st.ecsName("log")), st.ecsName("origin"))
+		file, line, fn := callerFields(r.caller)
+		st.ecsSet(st.ecsChild(origin, st.ecsName("file")), st.ecsName("name"), file)
+		st.ecsSet(st.ecsChild(origin, st.ecsName("file")), st.ecsName("line"), line)
+		st.ecsSet(origin, st.ecsName("function"), fn)
+	}
+	if r.err != nil {
+		e := st.ecsChild(root, st.ecsName("error"))
+		sc := &st.sc
+		sc.resetAt(r.err, 0)
+		for m, ok := sc.field(); ok; m, ok = sc.field() {
+			k := st.ecsKey(r.err[m.ks:m.ke])
+			if string(st.keys[k.start:k.end]) == "msg" {
+				k = st.ecsName("message")
+			}
+			st.ecsSet(e, k, r.err[m.vs:m.ve])
+		}
+	}
+	if r.stack != nil {
+		v := len(st.vals)
+		st.vals = st.appendStackTrace(st.vals, r.stack)
+		st.ecsLeaf(st.ecsChild(root, st.ecsName("error")), st.ecsName("stack_trace"), v)
+	}
+	v = len(st.vals)
+	st.vals = appendString(st.vals, ECSVersion, false)
+	st.ecsLeaf(st.ecsChild(root, st.ecsName("ecs")), st.ecsName("version"), v)
+	v = len(st.vals)
+	st.vals = appendString(st.vals, orDefault(f.Host, hostname), false)
+	st.ecsLeaf(st.ecsChild(root, st.ecsName("host")), st.ecsName("hostname"), v)
+	rest := root
+	if f.Namespace != "" {
+		rest = st.ecsChild(root, st.ecsName(f.Namespace))
+	}
+	for _, m := range r.rest {
+		n := rest
+		k := st.ecsKey(d[m.ks:m.ke])
+		for i := k.start; i < k.end; i++ {
+			if st.keys[i] == '.' {
+				n = st.ecsChild(n, span{k.start, i})
+				k.start = i + 1
+			}
+		}
+		st.ecsSet(n, k, d[m.vs:m.ve])
+	}
+	st.buf = st.ecsWrite(st.buf[:0], root)
+	st.buf = append(st.buf, '\n')
+	_, err := w.Write(st.buf)
+	return err
+}
+
+// ecsNode is a dict, or a leaf with a json value, in a document under
+// construction.  The key and value are spans of the keys and vals of a
+// schemaState, and the children of a dict are a list of nodes linked by
+// next, with 0, the index of the root, ending lists.
+type ecsNode struct {
+	key, val    span
+	leaf        bool
+	first, last int
+	next        int
+}
+
+// ecsName adds the key 's'.
+func (st *schemaState) ecsName(s string) span {
+	i := len(st.keys)
+	st.keys = append(st.keys, s...)
+	return span{i, len(st.keys)}
+}
+
+// ecsKey adds the key of the json string 'k'.
+func (st *schemaState) ecsKey(k []byte) span {
+	i := len(st.keys)
+	st.keys = AppendUnquote(st.keys, k)
+	return span{i, len(st.keys)}
+}
+
+// ecsFind returns the child of 'n' with key 'k', or 0 if there is none.
+func (st *schemaState) ecsFind(n int, k span) int {
+	for c := st.nodes[n].first; c != 0; c = st.nodes[c].next {
+		ck := st.nodes[c].key
+		if string(st.keys[ck.start:ck.end]) == string(st.keys[k.start:k.end]) {
+			return c
+		}
+	}
+	return 0
+}
+
+// ecsAdd adds 'c' as the last child of 'n' and returns its index.
+func (st *schemaState) ecsAdd(n int, c ecsNode) int {
+	i := len(st.nodes)
+	st.nodes = append(st.nodes, c)
+	if p := &st.nodes[n]; p.first == 0 {
+		p.first, p.last = i, i
+	} else {
+		st.nodes[p.last].next = i
+		p.last = i
+	}
+	return i
+}
+
+// ecsChild returns the dict with key 'k' in 'n', replacing any leaf.
+func (st *schemaState) ecsChild(n int, k span) int {
+	if c := st.ecsFind(n, k); c != 0 {
+		st.nodes[c].leaf = false
+		return c
+	}
+	return st.ecsAdd(n, ecsNode{key: k})
+}
+
+// ecsLeaf sets the field 'k' of 'n' to the json in st.vals from 'v' to
+// its end.
+func (st *schemaState) ecsLeaf(n int, k span, v int) {
+	val := span{v, len(st.vals)}
+	if c := st.ecsFind(n, k); c != 0 {
+		p := &st.nodes[c]
+		p.val, p.leaf, p.first, p.last = val, true, 0, 0
+		return
+	}
+	st.ecsAdd(n, ecsNode{key: k, val: val, leaf: true})
+}
+
+// ecsSet sets the field 'k' of 'n' to the raw json 'v'.  Dicts are merged
+// with any existing dict.
+func (st *schemaState) ecsSet(n int, k span, v []byte) {
+	if len(v) == 0 {
+		return
+	}
+	if v[0] == '{' {
+		c := st.ecsChild(n, k)
+		var sc Scanner
+		sc.resetAt(v, 0)
+		for m, ok := sc.field(); ok; m, ok = sc.field() {
+			st.ecsSet(c, st.ecsKey(v[m.ks:m.ke]), v[m.vs:m.ve])
+		}
+		return
+	}
+	i := len(st.vals)
+	st.vals = append(st.vals, v...)
+	st.ecsLeaf(n, k, i)
+}
+
+// ecsWrite appends the json of the node 'n' to 'buf'.
+func (st *schemaState) ecsWrite(buf []byte, n int) []byte {
+	p := &st.nodes[n]
+	if p.leaf {
+		return append(buf, st.vals[p.val.start:p.val.end]...)
+	}
+	buf = append(buf, '{')
+	for c := p.first; c != 0; c = st.nodes[c].next {
+		if c != p.first {
+			buf = append(buf, ',')
+		}
+		k := st.nodes[c].key
+		buf = appendString(buf, st.keys[k.start:k.end], false)
+		buf = append(buf, ':')
+		buf = st.ecsWrite(buf, c)
+	}
+	return append(buf, '}')
+}
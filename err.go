@@ -0,0 +1,156 @@
+package L
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorFielder is implemented by errors which carry structured fields.
+// ErrorFields adds the fields to the dict 'o' describing the error, as
+// with o.Field.
+type ErrorFielder interface {
+	ErrorFields(o *Obj)
+}
+
+// Err sets the field "Lerr" to a dict describing 'e', of the form
+//
+//	{"msg": "...", ..., "chain": [...], "join": [...]}
+//
+// where "msg" is e.Error(), followed by the fields of 'e' if it is an
+// ErrorFielder.  "chain" lists the errors found by successively calling
+// errors.Unwrap, each with its msg and fields.  "join" lists the branches
+// of an error with an 'Unwrap() []error' method, as created by errors.Join
+// or fmt.Errorf with several %w verbs, each described in full as for
+// 'e'.  An error in the chain may itself have a join.  "chain" and "join"
+// are omitted when empty.
+//
+// If 'e' is nil, "Lerr" is null.
+func (t *Obj) Err(e error) *Obj {
+	if t == nil {
+		return nil
+	}
+	if e == nil {
+		return t.Field("Lerr", nil)
+	}
+	c := t.key("Lerr").Dict()
+	encErr(c, e, 0)
+	c.Close()
+	return t
+}
+
+// Errf returns t.Err(fmt.Errorf(msgFmt, vs...))
+func (t *Obj) Errf(msgFmt string, vs ...any) *Obj {
+	return t.Err(fmt.Errorf(msgFmt, vs...))
+}
+
+// encErr writes the fields describing 'e' to the open dict 'o'.
+func encErr(o *Obj, e error, depth int) {
+	encErrNode(o, e, depth)
+	var chain *Obj
+	n := 0
+	for u := errors.Unwrap(e); u != nil && n < maxDepth; u = errors.Unwrap(u) {
+		if chain == nil {
+			chain = o.key("chain").Array()
+		}
+		c := chain.Dict()
+		encErrNode(c, u, depth)
+		c.Close()
+		n++
+	}
+	if chain != nil {
+		chain.Close()
+	}
+}
+
+// encErrNode writes the message, fields and join branches of 'e' to
+// 'o'.
+func encErrNode(o *Obj, e error, depth int) {
+	o.Field("msg", e.Error())
+	if f, ok := e.(ErrorFielder); ok {
+		f.ErrorFields(o)
+	}
+	bs := branches(e)
+	if len(bs) == 0 || depth >= maxDepth {
+		return
+	}
+	a := o.key("join").Array()
+	for _, b := range bs {
+		if b == nil {
+			continue
+		}
+		c := a.Dict()
+		encErr(c, b, depth+1)
+		c.Close()
+	}
+	a.Close()
+}
+
+// branches returns the errors wrapped by 'e' if it has an
+// 'Unwrap() []error' method.
+func branches(e error) []error {
+	if x, ok := e.(*Error); ok {
+		e = x.err
+	}
+	if j, ok := e.(interface{ Unwrap() []error }); ok {
+		return j.Unwrap()
+	}
+	return nil
+}
+
+// Error is an error carrying structured fields, which are written by
+// Obj.Err.  An Error is otherwise transparent: its message, chain and
+// join branches are those of the error from which it was created.
+type Error struct {
+	err    error
+	fields []errField
+}
+
+type errField struct {
+	key string
+	v   any
+}
+
+// Errorf returns an *Error for fmt.Errorf(msgFmt, vs...).
+func Errorf(msgFmt string, vs ...any) *Error {
+	return &Error{err: fmt.Errorf(msgFmt, vs...)}
+}
+
+// NewError returns an *Error for 'err', to which fields may be added.
+func NewError(err error) *Error {
+	return &Error{err: err}
+}
+
+// Field returns a copy of 'e' with the field 'key' set to 'v', which
+// is encoded as with Obj.Any.
+func (e *Error) Field(key string, v any) *Error {
+	fields := append(e.fields[:len(e.fields):len(e.fields)], errField{key: key, v: v})
+	return &Error{err: e.err, fields: fields}
+}
+
+func (e *Error) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns errors.Unwrap of the underlying error.
+func (e *Error) Unwrap() error {
+	return errors.Unwrap(e.err)
+}
+
+// Is returns errors.Is of the underlying error, so that join branches
+// are searched.
+func (e *Error) Is(target error) bool {
+	return errors.Is(e.err, target)
+}
+
+// As returns errors.As of the underlying error, so that join branches
+// are searched.
+func (e *Error) As(target any) bool {
+	return errors.As(e.err, target)
+}
+
+// ErrorFields implements ErrorFielder.
+func (e *Error) ErrorFields(o *Obj) {
+	for i := range e.fields {
+		o.Field(e.fields[i].key, e.fields[i].v)
+	}
+}
@@ -0,0 +1,66 @@
+package L
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// joinErr is as errors.Join, which is unavailable in go1.18.
+type joinErr []error
+
+func (j joinErr) Error() string   { return "joined" }
+func (j joinErr) Unwrap() []error { return j }
+
+func TestErr(t *testing.T) {
+	for _, tc := range []struct {
+		e    error
+		want string
+	}{
+		{nil, `{"Lerr":null}`},
+		{io.EOF, `{"Lerr":{"msg":"EOF"}}`},
+		{
+			fmt.Errorf("b: %w", fmt.Errorf("a: %w", io.EOF)),
+			`{"Lerr":{"msg":"b: a: EOF","chain":[{"msg":"a: EOF"},{"msg":"EOF"}]}}`,
+		},
+		{
+			Errorf("open %s: %w", "f", io.EOF).Field("path", "f").Field("n", 2),
+			`{"Lerr":{"msg":"open f: EOF","path":"f","n":2,"chain":[{"msg":"EOF"}]}}`,
+		},
+		{
+			fmt.Errorf("x: %w", joinErr{io.EOF, fmt.Errorf("y: %w", io.ErrClosedPipe)}),
+			`{"Lerr":{"msg":"x: joined","chain":[{"msg":"joined","join":[` +
+				`{"msg":"EOF"},` +
+				`{"msg":"y: io: read/write on closed pipe","chain":[{"msg":"io: read/write on closed pipe"}]}]}]}}`,
+		},
+		{
+			NewError(joinErr{NewError(io.EOF).Field("k", true)}),
+			`{"Lerr":{"msg":"joined","join":[{"msg":"EOF","k":true}]}}`,
+		},
+	} {
+		o := (&Obj{}).Dict().Err(tc.e)
+		if err := o.Close(); err != nil {
+			t.Errorf("%v: %v", tc.e, err)
+		}
+		if got := string(o.D()); got != tc.want {
+			t.Errorf("got  %s\nwant %s", got, tc.want)
+		}
+	}
+}
+
+func TestError(t *testing.T) {
+	base := Errorf("base").Field("a", 1)
+	e1, e2 := base.Field("b", 2), base.Field("c", 3)
+	if len(e1.fields) != 2 || len(e2.fields) != 2 || e1.fields[1].key != "b" {
+		t.Errorf("fields shared: %v %v", e1.fields, e2.fields)
+	}
+	e := NewError(joinErr{io.EOF})
+	if !errors.Is(e, io.EOF) {
+		t.Errorf("Is: not found in join")
+	}
+	var j joinErr
+	if !errors.As(Errorf("w: %w", e), &j) {
+		t.Errorf("As: not found")
+	}
+}
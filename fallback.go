@@ -0,0 +1,108 @@
+package L
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// FallbackWriter is an io.Writer which writes to a primary writer and,
+// for the records it fails to write, to a fallback writer such as
+// os.Stderr.  Records written to the fallback are not errors, but the
+// errors of the primary writer are counted, and the first error after a
+// successful write is reported through StatusReporter, so that the E of
+// the Config or Sink using the FallbackWriter learns of it once.
+//
+// A FallbackWriter is safe for concurrent use if its writers are.
+type FallbackWriter struct {
+	w, fallback io.Writer
+
+	mu      sync.Mutex
+	errors  int64
+	last    error
+	failing bool
+	events  []error
+}
+
+// NewFallbackWriter creates a FallbackWriter writing to 'w' and, when
+// that fails, to 'fallback', or os.Stderr if 'fallback' is nil.
+func NewFallbackWriter(w, fallback io.Writer) *FallbackWriter {
+	if fallback == nil {
+		fallback = os.Stderr
+	}
+	return &FallbackWriter{w: w, fallback: fallback}
+}
+
+// Write writes 'p' to the primary writer, or to the fallback writer if
+// that fails, returning an error only if both fail.
+func (f *FallbackWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if err == nil {
+		f.mu.Lock()
+		f.failing = false
+		f.mu.Unlock()
+		return n, nil
+	}
+	f.mu.Lock()
+	f.errors++
+	f.last = err
+	if !f.failing {
+		f.failing = true
+		f.events = append(f.events, fmt.Errorf("L: writing to fallback: %w", err))
+	}
+	f.mu.Unlock()
+	if _, ferr := f.fallback.Write(p); ferr != nil {
+		return n, err
+	}
+	return len(p), nil
+}
+
+// Errors returns the number of errors of the primary writer, and the
+// last one.
+func (f *FallbackWriter) Errors() (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.errors, f.last
+}
+
+// Status returns the first errors of the primary writer after each
+// successful write since the last call, and those reported by the
+// primary writer if it is a StatusReporter.
+func (f *FallbackWriter) Status() []error {
+	f.mu.Lock()
+	res := f.events
+	f.events = nil
+	f.mu.Unlock()
+	if sr, ok := f.w.(StatusReporter); ok {
+		res = append(res, sr.Status()...)
+	}
+	return res
+}
+
+func init() {
+	RegisterWriter("fallback", func(p json.RawMessage) (io.Writer, error) {
+		var fp struct {
+			W        *Spec `json:"w"`
+			Fallback *Spec `json:"fallback"`
+		}
+		if err := DecodeParams(p, &fp); err != nil {
+			return nil, err
+		}
+		if fp.W == nil {
+			return nil, fmt.Errorf("no w")
+		}
+		w, err := NewWriter(fp.W)
+		if err != nil {
+			return nil, err
+		}
+		var fb io.Writer
+		if fp.Fallback != nil {
+			if fb, err = NewWriter(fp.Fallback); err != nil {
+				return nil, err
+			}
+		}
+		return NewFallbackWriter(w, fb), nil
+	})
+}
@@ -0,0 +1,112 @@
+package L_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/scott-cotton/L"
+)
+
+// flakyWriter fails while fail is set.
+type flakyWriter struct {
+	fail bool
+	bytes.Buffer
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	if w.fail {
+		return 0, errors.New("disk full")
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestWriteErrors(t *testing.T) {
+	sink := bytes.NewBuffer(nil)
+	var errs []error
+	l := L.New(&L.Config{
+		W:     failWriter{},
+		F:     L.JSONFmter(),
+		E:     func(_ *L.Config, err error) { errs = append(errs, err) },
+		Sinks: []L.Sink{{Name: "s", W: sink, F: L.JSONFmter()}},
+	})
+	defer l.Close()
+	l.Dict().Field("a", 1).Log()
+	if sink.String() != `{"a":1}`+"\n" {
+		t.Errorf("sink got %q", sink.String())
+	}
+	var se *L.SinkError
+	if len(errs) != 1 || !errors.As(errs[0], &se) || se.Sink != "" || se.Package != "github.com/scott-cotton/L_test" {
+		t.Fatalf("errors %v", errs)
+	}
+	if got, want := se.Error(), "github.com/scott-cotton/L_test: fail"; got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+}
+
+func TestELogRecursion(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = stderr }()
+
+	l := L.New(&L.Config{
+		W:     failWriter{},
+		F:     L.JSONFmter(),
+		E:     L.ELog,
+		Sinks: []L.Sink{{Name: "s", W: failWriter{}, F: L.JSONFmter(), E: L.ELog}},
+	})
+	defer l.Close()
+	l.Dict().Field("a", 1).Log()
+	w.Close()
+	os.Stderr = stderr
+	d, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// ELog logs each of the 2 errors, of the writer and of the sink, to
+	// the failing writer and sink, and their errors go to standard error.
+	if got := strings.Count(string(d), `{"LE":"github.com/scott-cotton/L_test: `); got != 4 {
+		t.Errorf("got %d errors: %s", got, d)
+	}
+}
+
+func TestFallbackWriter(t *testing.T) {
+	primary := &flakyWriter{}
+	fallback := bytes.NewBuffer(nil)
+	fw := L.NewFallbackWriter(primary, fallback)
+	var errs []error
+	l := L.New(&L.Config{
+		W: fw,
+		F: L.JSONFmter(),
+		E: func(_ *L.Config, err error) { errs = append(errs, err) },
+	})
+	defer l.Close()
+	l.Dict().Field("a", 1).Log()
+	primary.fail = true
+	l.Dict().Field("b", 1).Log()
+	l.Dict().Field("c", 1).Log()
+	primary.fail = false
+	l.Dict().Field("d", 1).Log()
+	primary.fail = true
+	l.Dict().Field("e", 1).Log()
+
+	if got, want := primary.String(), "{\"a\":1}\n{\"d\":1}\n"; got != want {
+		t.Errorf("primary got %q want %q", got, want)
+	}
+	if got, want := fallback.String(), "{\"b\":1}\n{\"c\":1}\n{\"e\":1}\n"; got != want {
+		t.Errorf("fallback got %q want %q", got, want)
+	}
+	if n, err := fw.Errors(); n != 3 || err == nil {
+		t.Errorf("errors %d %v", n, err)
+	}
+	if len(errs) != 2 {
+		t.Errorf("reported %v", errs)
+	}
+}
@@ -1,8 +1,37 @@
 package L
 
-import "io"
+import (
+	"fmt"
+	"io"
+)
 
 // a Fmter formats logs
 type Fmter interface {
 	Fmt(w io.Writer, d []byte) error
 }
+
+// ConfigFmter is a Fmter which also formats with access to the Config of
+// the logger, such as its package.  Loggers call FmtConfig in place of Fmt
+// for Fmters implementing ConfigFmter.
+type ConfigFmter interface {
+	Fmter
+	FmtConfig(cfg *Config, w io.Writer, d []byte) error
+}
+
+// fmtSafe is fmtConfig, returning panics as errors.
+func fmtSafe(cfg *Config, f Fmter, w io.Writer, d []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fmtConfig(cfg, f, w, d)
+}
+
+// fmtConfig formats 'd' to 'w' with 'f', giving 'cfg' to ConfigFmters.
+func fmtConfig(cfg *Config, f Fmter, w io.Writer, d []byte) error {
+	if cf, ok := f.(ConfigFmter); ok {
+		return cf.FmtConfig(cfg, w, d)
+	}
+	return f.Fmt(w, d)
+}
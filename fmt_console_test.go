@@ -0,0 +1,65 @@
+package L
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestConsoleFmter(t *testing.T) {
+	for _, tc := range []struct {
+		f    ConsoleFmter
+		in   string
+		want string
+	}{
+		{
+			ConsoleFmter{Align: 30},
+			`{"k":1,"msg":"hi","time":"2022-01-02T03:04:05Z","Lpkg":"a/b","s":"x y","d":{"a":[1,"b"]}}`,
+			"2022-01-02T03:04:05Z a/b hi   k=1 s=\"x y\" d={a=[1 b]}\n",
+		},
+		{
+			ConsoleFmter{TimeFormat: "15:04:05", Align: 1},
+			`{"time":"2022-01-02T03:04:05Z","msg":"hi","e":""}`,
+			"03:04:05 hi e=\"\"\n",
+		},
+		{
+			ConsoleFmter{Align: 1},
+			`{"msg":"hi","err":"a\nb\n","Lstack":[{"file":"f.go","line":2}],"k":true}`,
+			"hi k=true\n    err=\n        a\n        b\n    Lstack=\n        {file=f.go line=2}\n",
+		},
+		{
+			ConsoleFmter{LevelKey: "lvl", Color: ColorAlways, Align: 1},
+			`{"msg":"hi","lvl":3}`,
+			"\x1b[31mhi\x1b[0m \x1b[34mlvl\x1b[0m=3\n",
+		},
+		{
+			ConsoleFmter{},
+			`[1,2]`,
+			"[1,2]\n",
+		},
+	} {
+		w := bytes.NewBuffer(nil)
+		if err := tc.f.Fmt(w, []byte(tc.in)); err != nil {
+			t.Fatal(err)
+		}
+		if got := w.String(); got != tc.want {
+			t.Errorf("%s:\ngot  %q\nwant %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestConsoleTerminalCached(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	c := &ConsoleFmter{}
+	if c.useColor(w) {
+		t.Errorf("pipe taken for a terminal")
+	}
+	if n := testing.AllocsPerRun(10, func() { c.useColor(w) }); n != 0 {
+		t.Errorf("%v allocations checking a known file", n)
+	}
+}
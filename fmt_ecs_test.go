@@ -0,0 +1,31 @@
+package L
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestECSFmter(t *testing.T) {
+	f := &ECSFmter{Host: "h", LevelKey: "lvl", Levels: []ECSLevel{{0, "info"}, {3, "error"}}}
+	w := bytes.NewBuffer(nil)
+	if err := f.Fmt(w, []byte(schemaRec)); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"@timestamp":"2022-01-02T03:04:05.5Z","message":"hi\nthere",` +
+		`"log":{"level":"error","logger":"a/b","origin":{"file":{"name":"f.go","line":12},"function":"a/b.F"}},` +
+		`"error":{"message":"boom","code":7,"chain":[{"msg":"x"}],"stack_trace":"a/b.F\n\tf.go:12\nmain.main\n\tg.go:3\n"},` +
+		`"ecs":{"version":"` + ECSVersion + `"},"host":{"hostname":"h"},` +
+		`"http":{"status":200,"ok":true},"id":"i","tags":["a"],"z":null,"a b":1}` + "\n"
+	if got := w.String(); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+
+	w.Reset()
+	f = &ECSFmter{Host: "h", Namespace: "app"}
+	f.Fmt(w, []byte(`{"time":"2022-01-02T03:04:05Z","log.file.path":"x","host.ip":"1.2.3.4","a.b":1,"a":{"c":2}}`))
+	want = `{"@timestamp":"2022-01-02T03:04:05Z","ecs":{"version":"` + ECSVersion + `"},"host":{"hostname":"h"},` +
+		`"app":{"log":{"file":{"path":"x"}},"host":{"ip":"1.2.3.4"},"a":{"b":1,"c":2}}}` + "\n"
+	if got := w.String(); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
@@ -0,0 +1,98 @@
+package L
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+var schemaRec = `{"time":"2022-01-02T03:04:05.5Z","msg":"hi\nthere","lvl":3,` +
+	`"Lpkg":"a/b","Lerr":{"msg":"boom","code":7,"chain":[{"msg":"x"}]},` +
+	`"Lcaller":{"file":"f.go","line":12,"func":"a/b.F"},` +
+	`"Lstack":[{"file":"f.go","line":12,"func":"a/b.F"},{"file":"g.go","line":3,"func":"main.main"}],` +
+	`"http":{"status":200,"ok":true},"id":"i","tags":["a"],"z":null,"a b":1}`
+
+func TestGELFFmter(t *testing.T) {
+	f := &GELFFmter{Host: "h", LevelKey: "lvl", Levels: []SyslogLevel{{Min: 3, Severity: SevErr}}}
+	w := bytes.NewBuffer(nil)
+	if err := f.Fmt(w, []byte(schemaRec)); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"version":"1.1","host":"h","short_message":"hi","full_message":"hi\nthere",` +
+		`"timestamp":1641092645.500000,"level":3,"_logger_name":"a/b",` +
+		`"_error_message":"boom","_error.code":7,"_error.chain":"[{\"msg\":\"x\"}]",` +
+		`"_file":"f.go","_line":12,"_function":"a/b.F",` +
+		`"_stack_trace":"a/b.F\n\tf.go:12\nmain.main\n\tg.go:3\n",` +
+		`"_http.status":200,"_http.ok":"true","_id_":"i","_tags":"[\"a\"]","_a_b":1}` + "\n"
+	if got := w.String(); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestGELFWriter(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	w, err := DialGELF(pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.ChunkSize = 100
+	read := func() []byte {
+		buf := make([]byte, 2048)
+		pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return buf[:n]
+	}
+	small := `{"version":"1.1","short_message":"a"}`
+	w.Write([]byte(small + "\n"))
+	if got := string(read()); got != small {
+		t.Errorf("got %s", got)
+	}
+
+	big := `{"version":"1.1","short_message":"` + strings.Repeat("x", 1000) + `"}`
+	w.Write([]byte(big))
+	var chunks [][]byte
+	for {
+		c := read()
+		if c[0] != 0x1e || c[1] != 0x0f || len(c) > 100 {
+			t.Fatalf("bad chunk %q", c[:12])
+		}
+		chunks = append(chunks, c)
+		if len(chunks) == int(c[11]) {
+			break
+		}
+	}
+	var msg []byte
+	for i, c := range chunks {
+		if int(c[10]) != i || !bytes.Equal(c[2:10], chunks[0][2:10]) {
+			t.Fatalf("chunk %d: bad header", i)
+		}
+		msg = append(msg, c[12:]...)
+	}
+	if string(msg) != big {
+		t.Errorf("reassembled %d bytes", len(msg))
+	}
+
+	w.Compress = true
+	w.Write([]byte(big))
+	z, err := gzip.NewReader(bytes.NewReader(read()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, _ := io.ReadAll(z)
+	if !json.Valid(d) || string(d) != big {
+		t.Errorf("compressed: %d bytes", len(d))
+	}
+}
@@ -0,0 +1,48 @@
+package L
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLogfmtFmter(t *testing.T) {
+	for _, tc := range []struct {
+		f    LogfmtFmter
+		in   string
+		want string
+	}{
+		{
+			LogfmtFmter{},
+			`{"msg":"hello world","n":1.5,"ok":true,"z":null,"e":"","q":"a\"b=c\n"}`,
+			`msg="hello world" n=1.5 ok=true z=null e="" q="a\"b=c\n"`,
+		},
+		{
+			LogfmtFmter{},
+			`{"http":{"status":200,"req":{"path":"/x"}},"ids":[7,{"a":1}],"d":{},"a":[]}`,
+			`http.status=200 http.req.path=/x ids.0=7 ids.1.a=1 d={} a=[]`,
+		},
+		{
+			LogfmtFmter{First: []string{"msg", "http", "time"}},
+			`{"x":1,"http":{"status":200},"y":2,"msg":"m","http2":3}`,
+			`msg=m http.status=200 x=1 y=2 http2=3`,
+		},
+		{
+			LogfmtFmter{},
+			`{"a b=\"c":1,"":2,"é":"é"}`,
+			`a_b__c=1 _=2 é=é`,
+		},
+		{
+			LogfmtFmter{},
+			`"x"`,
+			`"x"`,
+		},
+	} {
+		w := bytes.NewBuffer(nil)
+		if err := tc.f.Fmt(w, []byte(tc.in)); err != nil {
+			t.Fatal(err)
+		}
+		if got := w.String(); got != tc.want+"\n" {
+			t.Errorf("%s:\ngot  %s\nwant %s", tc.in, got, tc.want)
+		}
+	}
+}
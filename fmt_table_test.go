@@ -1,7 +1,97 @@
 package L
 
-import "testing"
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+)
 
 func TestTableFmter(t *testing.T) {
+	lines := []string{
+		`{"msg":"hello","http":{"status":200},"Lerr":{"msg":"x"},"f":0.5}`,
+		`{"msg":"a much longer message","f":2,"z":null}`,
+		`{"msg":"say \"hi\", ok","http":{"status":404},"f":[1]}`,
+	}
+	for _, tc := range []struct {
+		f    *TableFmter
+		want string
+	}{
+		{
+			&TableFmter{Fields: []string{"msg", "http.status", "Lerr.msg", "f"}},
+			"hello 200 x 0.5\n" +
+				"a much longer message - - 2\n" +
+				"say \"hi\", ok 404 - [1]\n",
+		},
+		{
+			&TableFmter{
+				Fields:    []string{"msg", "http.status", "f"},
+				Widths:    []int{10},
+				AutoWidth: true,
+				Header:    true,
+				Sep:       " | ",
+				FloatFmt:  'f',
+				FloatPrec: 1,
+			},
+			"msg        | http.status | f\n" +
+				"hello      | 200         | 0.5\n" +
+				"a much lo… | -           | 2\n" +
+				"say \"hi\",… | 404         | [1]\n",
+		},
+		{
+			&TableFmter{Fields: []string{"msg", "f"}, Keys: true, Sep: ",", Missing: "?"},
+			"msg=hello,f=0.5\n" +
+				"msg=a much longer message,f=2\n" +
+				"msg=say \"hi\", ok,f=[1]\n",
+		},
+		{
+			&TableFmter{Fields: []string{"msg", "http.status"}, Mode: TableCSV, Header: true},
+			"msg,http.status\n" +
+				"hello,200\n" +
+				"a much longer message,\n" +
+				"\"say \"\"hi\"\", ok\",404\n",
+		},
+		{
+			&TableFmter{Fields: []string{"msg", "z"}, Mode: TableTSV, Missing: "NA"},
+			"hello\tNA\n" +
+				"a much longer message\tNA\n" +
+				"\"say \"\"hi\"\", ok\"\tNA\n",
+		},
+	} {
+		w := bytes.NewBuffer(nil)
+		for _, ln := range lines {
+			if err := tc.f.Fmt(w, []byte(ln)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := w.String(); got != tc.want {
+			t.Errorf("got\n%s\nwant\n%s", got, tc.want)
+		}
+	}
+}
 
+func TestTableFmterShared(t *testing.T) {
+	f := &TableFmter{Fields: []string{"a"}, AutoWidth: true, Header: true}
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+		w  bytes.Buffer
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var b bytes.Buffer
+			for j := 0; j < 100; j++ {
+				f.Fmt(&b, []byte(`{"a":1}`))
+			}
+			mu.Lock()
+			w.Write(b.Bytes())
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if n := strings.Count(w.String(), "1\n"); n != 800 {
+		t.Errorf("got %d lines", n)
+	}
 }
@@ -0,0 +1,40 @@
+package L
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTemplateFmter(t *testing.T) {
+	for _, tc := range []struct {
+		tmpl string
+		in   string
+		want string
+	}{
+		{`{{.msg}} {{.n}}`, `{"msg":"hi","n":12345678901234567890}`, "hi 12345678901234567890\n"},
+		{`{{json .http}}` + "\n", `{"http":{"status":200}}`, "{\"status\":200}\n"},
+		{`[{{.msg | pad 5}}][{{.n | pad -4}}]`, `{"msg":"ab","n":7}`, "[ab   ][   7]\n"},
+		{`{{.user | default "-"}} {{.x | default "-"}}`, `{"user":"","x":0}`, "- 0\n"},
+		{`{{.time | time "15:04"}} {{.n | time "15:04"}}`, `{"time":"2022-01-02T03:04:05Z","n":1}`, "03:04 1\n"},
+		{`{{label ".debug" .}} {{label "a/b.info" .}} {{label ".x" .}}`, `{"a/b.debug":2,"a/b.info":1}`, "2 1 <no value>\n"},
+		{`{{label ".debug" .}}`, `{"c/d.debug":3,"a/b.debug":2,"b.debug":4}`, "2\n"},
+		{`{{index . 1}}`, `[1,"b"]`, "b\n"},
+	} {
+		f, err := NewTemplateFmter(tc.tmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := bytes.NewBuffer(nil)
+		if err := f.Fmt(w, []byte(tc.in)); err != nil {
+			t.Fatalf("%s: %v", tc.tmpl, err)
+		}
+		if got := w.String(); got != tc.want {
+			t.Errorf("%s: got %q want %q", tc.tmpl, got, tc.want)
+		}
+	}
+	for _, bad := range []string{`{{.msg`, `{{nosuch .}}`, `{{end}}`} {
+		if _, err := NewTemplateFmter(bad); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package L
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// GELFFmter is a Fmter writing dicts as GELF 1.1 messages for Graylog,
+// one per line.
+//
+// The field with key MsgKey becomes the short_message, with the
+// full_message holding all of it if it has several lines, and the RFC3339
+// time with key TimeKey becomes the timestamp.  Fields written by L are
+// renamed: "Lpkg" to "_logger_name", the msg of "Lerr" to
+// "_error_message", "Lcaller" to "_file", "_line" and "_function" and
+// "Lstack" to "_stack_trace".  Other fields are additional fields, with
+// nested dicts flattened to dotted keys and arrays written as json strings,
+// since GELF permits only strings and numbers.
+//
+// If LevelKey is set, the value of the field with that key, such as a
+// label added by the Label middleware, selects the severity of the
+// level from Levels as in SyslogFmter.  Otherwise, the level is SevInfo.
+type GELFFmter struct {
+	// Host is the host, os.Hostname if empty.
+	Host string `json:"host,omitempty"`
+	// TimeKey, TimeLayout and MsgKey default to "time",
+	// time.RFC3339Nano and "msg".  TimeLayout should be that given to
+	// the TimeFormat middleware, if it is used.
+	TimeKey    string `json:"timeKey,omitempty"`
+	TimeLayout string `json:"timeLayout,omitempty"`
+	MsgKey     string `json:"msgKey,omitempty"`
+	// LevelKey and Levels determine the level.
+	LevelKey string        `json:"levelKey,omitempty"`
+	Levels   []SyslogLevel `json:"levels,omitempty"`
+}
+
+func (f *GELFFmter) Fmt(w io.Writer, d []byte) error {
+	st := schemaPool.Get().(*schemaState)
+	defer schemaPool.Put(st)
+	r := st.split(d, schemaKeys{
+		timeKey:    f.TimeKey,
+		timeLayout: f.TimeLayout,
+		msgKey:     f.MsgKey,
+		levelKey:   f.LevelKey,
+	})
+	buf := append(st.buf[:0], `{"version":"1.1","host":`...)
+	buf = appendString(buf, orDefault(f.Host, hostname), false)
+	msg := d
+	if r.msg != nil {
+		st.text = appendValueText(st.text[:0], r.msg)
+		msg = st.text
+	}
+	buf = append(buf, `,"short_message":`...)
+	if i := bytes.IndexByte(msg, '\n'); i != -1 {
+		buf = appendString(buf, msg[:i], false)
+		buf = append(buf, `,"full_message":`...)
+		buf = appendString(buf, msg, false)
+	} else if len(msg) != 0 {
+		buf = appendString(buf, msg, false)
+	} else {
+		buf = append(buf, `"-"`...)
+	}
+	if !r.time.IsZero() {
+		buf = append(buf, `,"timestamp":`...)
+		buf = strconv.AppendInt(buf, r.time.Unix(), 10)
+		buf = append(buf, '.')
+		us := r.time.Nanosecond() / 1000
+		for p := 100000; p > 1; p /= 10 {
+			if us < p {
+				buf = append(buf, '0')
+			}
+		}
+		buf = strconv.AppendInt(buf, int64(us), 10)
+	}
+	sev := SevInfo
+	if r.leveled {
+		for _, l := range f.Levels {
+			if l.Min <= r.level {
+				sev = l.Severity
+			}
+		}
+	}
+	buf = append(buf, `,"level":`...)
+	buf = strconv.AppendInt(buf, int64(sev), 10)
+	if r.pkg != nil {
+		buf = append(buf, `,"_logger_name":`...)
+		buf = st.appendTextString(buf, r.pkg)
+	}
+	st.buf = buf
+	if r.err != nil {
+		sc := &st.sc
+		sc.resetAt(r.err, 0)
+		for m, ok := sc.field(); ok; m, ok = sc.field() {
+			k, v := r.err[m.ks:m.ke], r.err[m.vs:m.ve]
+			if keyIs(k, "msg") {
+				st.buf = append(st.buf, `,"_error_message":`...)
+				st.buf = st.appendTextString(st.buf, v)
+			} else {
+				st.key = append(st.key[:0], "error."...)
+				st.key = AppendUnquote(st.key, k)
+				st.gelfField(len(st.key), v)
+			}
+		}
+	}
+	buf = st.buf
+	if r.caller != nil {
+		file, line, fn := callerFields(r.caller)
+		buf = append(buf, `,"_file":`...)
+		buf = st.appendTextString(buf, file)
+		if line != nil {
+			buf = append(buf, `,"_line":`...)
+			buf = append(buf, line...)
+		}
+		buf = append(buf, `,"_function":`...)
+		buf = st.appendTextString(buf, fn)
+	}
+	if r.stack != nil {
+		buf = append(buf, `,"_stack_trace":`...)
+		buf = st.appendStackTrace(buf, r.stack)
+	}
+	st.buf = buf
+	for _, m := range r.rest {
+		st.key = AppendUnquote(st.key[:0], d[m.ks:m.ke])
+		st.gelfField(len(st.key), d[m.vs:m.ve])
+	}
+	st.buf = append(st.buf, '}', '\n')
+	_, err := w.Write(st.buf)
+	return err
+}
+
+// gelfField appends the additional field with key st.key[:n] and value
+// 'v' to st.buf, flattening dicts.
+func (st *schemaState) gelfField(n int, v []byte) {
+	switch v[0] {
+	case '{':
+		var sc Scanner
+		sc.resetAt(v, 0)
+		for m, ok := sc.field(); ok; m, ok = sc.field() {
+			st.key = append(st.key[:n], '.')
+			st.key = AppendUnquote(st.key, v[m.ks:m.ke])
+			st.gelfField(len(st.key), v[m.vs:m.ve])
+		}
+		return
+	case 'n':
+		return
+	}
+	buf := append(st.buf, ',', '"', '_')
+	for _, c := range st.key[:n] {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.' || c == '-') {
+			c = '_'
+		}
+		buf = append(buf, c)
+	}
+	if string(st.key[:n]) == "id" {
+		buf = append(buf, '_')
+	}
+	buf = append(buf, '"', ':')
+	switch v[0] {
+	case '"', '[':
+		buf = st.appendTextString(buf, v)
+	case 't', 'f':
+		buf = appendString(buf, v, false)
+	default:
+		buf = append(buf, v...)
+	}
+	st.buf = buf
+}
+
+// GELFWriter is an io.WriteCloser sending each Write over UDP to a
+// Graylog GELF input as one message, for example as formatted by
+// GELFFmter.  A trailing newline is removed.  Messages larger than
+// ChunkSize are sent in chunks, as in the GELF specification.
+//
+// A GELFWriter is safe for concurrent use.
+type GELFWriter struct {
+	// ChunkSize is the maximum size of a datagram, 1420 if zero.
+	ChunkSize int
+	// Compress causes messages to be compressed with gzip.
+	Compress bool
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// DialGELF creates a GELFWriter sending to the UDP address 'addr'.
+func DialGELF(addr string) (*GELFWriter, error) {
+	c, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &GELFWriter{conn: c}, nil
+}
+
+const (
+	gelfHeader    = 12
+	gelfMaxChunks = 128
+)
+
+func (w *GELFWriter) Write(p []byte) (int, error) {
+	msg := bytes.TrimSuffix(p, []byte{'\n'})
+	if w.Compress {
+		var b bytes.Buffer
+		z := gzip.NewWriter(&b)
+		z.Write(msg)
+		z.Close()
+		msg = b.Bytes()
+	}
+	size := w.ChunkSize
+	if size == 0 {
+		size = 1420
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(msg) <= size {
+		if _, err := w.conn.Write(msg); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	payload := size - gelfHeader
+	if payload <= 0 {
+		return 0, errors.New("gelf: chunk size too small")
+	}
+	n := (len(msg) + payload - 1) / payload
+	if n > gelfMaxChunks {
+		return 0, errors.New("gelf: message too large")
+	}
+	chunk := make([]byte, size)
+	chunk[0], chunk[1] = 0x1e, 0x0f
+	if _, err := rand.Read(chunk[2:10]); err != nil {
+		return 0, err
+	}
+	chunk[11] = byte(n)
+	for i := 0; i < n; i++ {
+		chunk[10] = byte(i)
+		end := (i + 1) * payload
+		if end > len(msg) {
+			end = len(msg)
+		}
+		m := copy(chunk[gelfHeader:], msg[i*payload:end])
+		if _, err := w.conn.Write(chunk[:gelfHeader+m]); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Close closes the connection of 'w'.
+func (w *GELFWriter) Close() error {
+	return w.conn.Close()
+}
@@ -0,0 +1,159 @@
+package L
+
+import "errors"
+
+// lazy is a function adding fields to a dict, deferred
+// until the dict is logged.
+type lazy struct {
+	at  int // the offset of the dict
+	pos int // the offset at which to insert the fields
+	fn  func(*Obj)
+}
+
+// LazyField adds a field with key 'key' and value 'fn()' to 't', which
+// should be a dict.  'fn' is called only when 't' is logged, after the
+// Post middlewares of the logger have run and only if the object is to be
+// formatted.  The field is placed as if 'fn' were called immediately.
+func (t *Obj) LazyField(key string, fn func() any) *Obj {
+	return t.LazyFn(func(o *Obj) {
+		o.Field(key, fn())
+	})
+}
+
+// LazyFn defers the call 'fn(o)' until 't' is logged, after the Post
+// middlewares of the logger have run and only if the object is to be
+// formatted.  'o' is a dict, and any fields added to it are placed in 't'
+// as if 'fn' were called immediately.  The deferred functions of an
+// object are called in the order they were added.
+//
+// 't' should be an open dict, or a root whose value is a dict, in which
+// case the fields are placed at its end.  On a root without a value, such
+// as an object given to Pre middlewares, the fields are placed first in
+// the dict it becomes, if any.  LazyFn on other objects is an error,
+// reported when 't' is logged.
+func (t *Obj) LazyFn(fn func(o *Obj)) *Obj {
+	if t == nil {
+		return t
+	}
+	if t.child != nil {
+		panic("nonlinear")
+	}
+	at, pos, ok := t.lazyAt()
+	r := t.getRoot()
+	switch {
+	case !ok:
+		t.fail(errors.New("LazyFn on an object which is not a dict"))
+	case at == -1:
+		r.pending = append(r.pending, fn)
+	default:
+		r.lazy = append(r.lazy, lazy{at: at, pos: pos, fn: fn})
+	}
+	return t
+}
+
+// lazyAt returns the offsets of the dict of 't' and of the position at
+// which LazyFn places fields, with 'at' -1 for a root without a value,
+// and false if 't' is not an object accepted by LazyFn.
+func (t *Obj) lazyAt() (at, pos int, ok bool) {
+	if t.kind == '{' && !t.closed {
+		return t.i, len(*t.buf()), true
+	}
+	if !t.IsRoot() || t.child != nil {
+		return 0, 0, false
+	}
+	d := t.d
+	i := skipSpace(d, 0)
+	if i == len(d) {
+		return -1, 0, true
+	}
+	j := skipSpaceBack(d, len(d)) - 1
+	if d[i] != '{' || j <= i || d[j] != '}' {
+		return 0, 0, false
+	}
+	return i, j, true
+}
+
+// startPending makes the deferred functions added to the root of 't'
+// before it had a value those of 't', a new dict.
+func (t *Obj) startPending() {
+	r := t.getRoot()
+	for _, fn := range r.pending {
+		r.lazy = append(r.lazy, lazy{at: t.i, pos: len(r.d), fn: fn})
+	}
+	r.pending = nil
+}
+
+// runLazy calls the deferred functions in the tree of 't', placing their
+// results in the buffer.
+func (t *Obj) runLazy() {
+	if t == nil {
+		return
+	}
+	r := t.getRoot()
+	ls := r.lazy
+	r.lazy, r.pending = nil, nil
+	// the functions are called in order, and as lazies are in
+	// order of position, inserting their fields from the last one
+	// leaves the positions of the others intact.
+	outs := make([][]byte, len(ls))
+	for i := range ls {
+		s := t.scratch()
+		c := s.Dict()
+		ls[i].fn(c)
+		c.runLazy()
+		if err := c.Close(); err != nil {
+			t.fail(err)
+			continue
+		}
+		outs[i] = s.d[1 : len(s.d)-1]
+	}
+	for i := len(ls) - 1; i >= 0; i-- {
+		l := &ls[i]
+		fields := outs[i]
+		if len(fields) == 0 {
+			continue
+		}
+		d := r.d
+		ins := make([]byte, 0, len(fields)+2)
+		if j := skipSpaceBack(d, l.pos); j > 0 && d[j-1] != '{' {
+			ins = append(ins, ',')
+		}
+		ins = append(ins, fields...)
+		if j := skipSpace(d, l.pos); j < len(d) && d[j] == '"' {
+			ins = append(ins, ',')
+		}
+		t.replaceBytes(l.pos, l.pos, ins)
+	}
+}
+
+// moveLazy adjusts the positions of deferred functions in the tree of 't'
+// when the bytes from 'a' to 'b' are replaced with 'n' bytes.
+func (t *Obj) moveLazy(a, b, n int) {
+	r := t.getRoot()
+	delta := n - (b - a)
+	j := 0
+	for _, l := range r.lazy {
+		switch {
+		case l.at >= a && l.at < b && a < b:
+			// the dict was removed.
+			continue
+		case l.pos > a && l.pos < b:
+			l.pos = a
+		case l.pos >= b && l.pos > a:
+			l.pos += delta
+		}
+		if l.at >= b && l.at > a {
+			l.at += delta
+		}
+		r.lazy[j] = l
+		j++
+	}
+	r.lazy = r.lazy[:j]
+}
+
+func skipSpaceBack(d []byte, i int) int {
+	for i > 0 && isSpace(d[i-1]) {
+		i--
+	}
+	return i
+}
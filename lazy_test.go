@@ -0,0 +1,107 @@
+package L_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/scott-cotton/L"
+)
+
+func TestLazy(t *testing.T) {
+	w := bytes.NewBuffer(nil)
+	cfg := &L.Config{
+		Labels: map[string]int{"on": 1},
+		W:      w,
+		F:      L.JSONFmter(),
+		E:      L.EPanic,
+		Post:   []L.Middleware{L.If("on"), L.Label("on")},
+	}
+	l := L.New(cfg)
+	calls := 0
+	val := func() any {
+		calls++
+		return calls
+	}
+	l.Dict().
+		LazyField("a", val).
+		Field("b", 2).
+		LazyFn(func(o *L.Obj) {
+			o.Field("c", 3).LazyField("d", val)
+		}).
+		Field("e", 5).
+		LazyField("f", val).
+		Log()
+	want := `{"a":1,"b":2,"c":3,"d":2,"e":5,"f":3,"on":1}` + "\n"
+	if got := w.String(); got != want {
+		t.Errorf("got %s want %s", got, want)
+	}
+
+	// nested and spliced lazies
+	w.Reset()
+	arr := l.Array()
+	arr.Dict().LazyField("x", func() any { return "x" })
+	l.Dict().LazyField("y", func() any { return "y" }).Set("arr", arr).Log()
+	want = `{"y":"y","arr":[{"x":"x"}],"on":1}` + "\n"
+	if got := w.String(); got != want {
+		t.Errorf("got %s want %s", got, want)
+	}
+
+	// filtered out by Post
+	w.Reset()
+	calls = 0
+	off := l.WithMap(nil)
+	off.ApplyConfig(&L.Config{Labels: map[string]int{}}, &L.ApplyOpts{RemoveAbsentLabels: true})
+	off.Dict().LazyField("a", val).Log()
+	if calls != 0 || w.Len() != 0 {
+		t.Errorf("lazy field evaluated for filtered object: %d %q", calls, w.String())
+	}
+
+	// deleted by Post
+	w.Reset()
+	del := L.New(&L.Config{
+		W: w,
+		F: L.JSONFmter(),
+		E: L.EPanic,
+		Post: []L.Middleware{func(_ *L.Config, o *L.Obj) *L.Obj {
+			o.Delete("a")
+			return o
+		}},
+	})
+	del.Dict().Field("a", 1).LazyField("b", val).Field("c", 3).Log()
+	want = `{"b":1,"c":3}` + "\n"
+	if got := w.String(); got != want {
+		t.Errorf("got %s want %s", got, want)
+	}
+}
+
+func TestLazyRoots(t *testing.T) {
+	w := bytes.NewBuffer(nil)
+	var errs []error
+	pre := func(_ *L.Config, o *L.Obj) *L.Obj {
+		return o.LazyField("pre", func() any { return 1 })
+	}
+	l := L.New(&L.Config{
+		W:   w,
+		F:   L.JSONFmter(),
+		E:   func(_ *L.Config, err error) { errs = append(errs, err) },
+		Pre: []L.Middleware{pre},
+	})
+	defer l.Close()
+	l.Dict().Field("a", 2).Log()
+	l.Str("s").Log()
+	if got, want := w.String(), `{"pre":1,"a":2}`+"\n"+`"s"`+"\n"; got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+
+	o := &L.Obj{}
+	o.Dict().Field("a", 1).Close()
+	o.LazyField("b", func() any { return 2 })
+	if err := o.Close(); err != nil {
+		t.Fatal(err)
+	}
+	w.Reset()
+	l.Array().Int(1).LazyField("b", func() any { return 2 }).Log()
+	if w.Len() != 0 || len(errs) != 1 {
+		t.Errorf("misuse not reported: %q %v", w.String(), errs)
+	}
+}
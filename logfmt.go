@@ -0,0 +1,177 @@
+package L
+
+import (
+	"io"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// LogfmtFmter is a Fmter writing dicts in logfmt, as a line of
+// space-separated key=value pairs in the order the fields were written.
+// Nested dicts and arrays are flattened to dotted keys, as in
+// "http.status=200" or "ids.0=7".  Strings are quoted when they are empty
+// or contain spaces, '=', quotes or non-printable characters, and null is
+// written as null.  Characters which may not appear in a logfmt key are
+// replaced by '_'.
+//
+// Objects other than dicts are written as json.
+type LogfmtFmter struct {
+	// First lists the keys of fields written before all others, in
+	// the given order.  A key also matches the flattened keys it
+	// prefixes, so "http" matches "http.status".
+	First []string `json:"first,omitempty"`
+}
+
+// logfmtPair is a flattened field, with its key in logfmtState.keys.
+type logfmtPair struct {
+	ks, ke int
+	vs, ve int
+}
+
+// logfmtState holds the buffers of a call to LogfmtFmter.Fmt.
+type logfmtState struct {
+	sc     Scanner
+	path   []byte
+	frames []pathFrame
+	keys   []byte
+	pairs  []logfmtPair
+	done   []bool
+	buf    []byte
+}
+
+var logfmtPool = sync.Pool{
+	New: func() any { return &logfmtState{} },
+}
+
+func (f *LogfmtFmter) Fmt(w io.Writer, d []byte) error {
+	i := skipSpace(d, 0)
+	if i == len(d) || d[i] != '{' {
+		_, err := w.Write(append(d[:len(d):len(d)], '\n'))
+		return err
+	}
+	st := logfmtPool.Get().(*logfmtState)
+	defer logfmtPool.Put(st)
+	st.flatten(d)
+	buf := st.buf[:0]
+	st.done = st.done[:0]
+	for range st.pairs {
+		st.done = append(st.done, false)
+	}
+	for _, k := range f.First {
+		for j := range st.pairs {
+			p := &st.pairs[j]
+			key := st.keys[p.ks:p.ke]
+			if st.done[j] || !(string(key) == k || len(key) > len(k) && key[len(k)] == '.' && string(key[:len(k)]) == k) {
+				continue
+			}
+			buf = st.appendPair(buf, d, p)
+			st.done[j] = true
+		}
+	}
+	for j := range st.pairs {
+		if !st.done[j] {
+			buf = st.appendPair(buf, d, &st.pairs[j])
+		}
+	}
+	if len(buf) > 0 {
+		buf = buf[:len(buf)-1]
+	}
+	buf = append(buf, '\n')
+	st.buf = buf
+	_, err := w.Write(buf)
+	return err
+}
+
+// flatten sets st.pairs to the leaf values of the dict 'd', with
+// dotted keys.
+func (st *logfmtState) flatten(d []byte) {
+	st.keys = st.keys[:0]
+	st.pairs = st.pairs[:0]
+	st.path = st.path[:0]
+	st.frames = st.frames[:0]
+	sc := &st.sc
+	sc.Reset(d)
+	for {
+		tok := sc.Next()
+		switch tok.Kind {
+		case KindEnd:
+			return
+		case KindDictEnd, KindArrayEnd:
+			fr := st.frames[len(st.frames)-1]
+			st.frames = st.frames[:len(st.frames)-1]
+			if fr.n == 0 && len(st.frames) > 0 {
+				// keep empty dicts and arrays
+				st.path = st.path[:fr.base]
+				st.addPair(fr.start, tok.End)
+			}
+			continue
+		case KindKey:
+			fr := &st.frames[len(st.frames)-1]
+			fr.n++
+			st.path = st.path[:fr.base]
+			if fr.base > 0 {
+				st.path = append(st.path, '.')
+			}
+			st.path = AppendUnquote(st.path, d[tok.Start:tok.End])
+			continue
+		}
+		if n := len(st.frames); n > 0 && !st.frames[n-1].dict {
+			fr := &st.frames[n-1]
+			st.path = st.path[:fr.base]
+			if fr.base > 0 {
+				st.path = append(st.path, '.')
+			}
+			st.path = strconv.AppendInt(st.path, int64(fr.n), 10)
+			fr.n++
+		}
+		if tok.Kind == KindDict || tok.Kind == KindArray {
+			st.frames = append(st.frames, pathFrame{start: tok.Start, base: len(st.path), dict: tok.Kind == KindDict})
+			continue
+		}
+		st.addPair(tok.Start, tok.End)
+	}
+}
+
+// addPair adds a pair with the current path and the value from d[vs]
+// to d[ve].
+func (st *logfmtState) addPair(vs, ve int) {
+	ks := len(st.keys)
+	st.keys = appendLogfmtKey(st.keys, st.path)
+	st.pairs = append(st.pairs, logfmtPair{ks: ks, ke: len(st.keys), vs: vs, ve: ve})
+}
+
+// appendPair appends the pair 'p' followed by a space.
+func (st *logfmtState) appendPair(buf, d []byte, p *logfmtPair) []byte {
+	buf = append(buf, st.keys[p.ks:p.ke]...)
+	buf = append(buf, '=')
+	v := d[p.vs:p.ve]
+	switch v[0] {
+	case '"':
+		st.path = AppendUnquote(st.path[:0], v)
+		buf = appendText(buf, st.path)
+	case '{':
+		buf = append(buf, "{}"...)
+	case '[':
+		buf = append(buf, "[]"...)
+	default:
+		buf = append(buf, v...)
+	}
+	return append(buf, ' ')
+}
+
+func appendLogfmtKey(buf, k []byte) []byte {
+	if len(k) == 0 {
+		return append(buf, '_')
+	}
+	for i := 0; i < len(k); {
+		r, size := utf8.DecodeRune(k[i:])
+		if r <= ' ' || r == '=' || r == '"' || r == 0x7f || r == utf8.RuneError {
+			buf = append(buf, '_')
+		} else {
+			buf = append(buf, k[i:i+size]...)
+		}
+		i += size
+	}
+	return buf
+}
@@ -62,10 +62,15 @@ type logger struct {
 }
 
 func (l *logger) With(key string, v int) Logger {
-	return l.WithMap(map[string]int{key: v})
+	return l.withMap(map[string]int{key: v}, 2)
 }
 
 func (l *logger) WithMap(labels map[string]int) Logger {
+	return l.withMap(labels, 2)
+}
+
+// withMap is WithMap for the caller 'skip' frames up.
+func (l *logger) withMap(labels map[string]int, skip int) Logger {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 	cfg := l.config.Clone()
@@ -76,7 +81,7 @@ func (l *logger) WithMap(labels map[string]int) Logger {
 		}
 		cfg.Labels[key] = v
 	}
-	pc, _, _, _ := runtime.Caller(1)
+	pc, _, _, _ := runtime.Caller(skip)
 	fn := runtime.FuncForPC(pc).Name()
 	i := strings.LastIndexByte(fn, byte('.'))
 	j := strings.IndexByte(fn, byte('('))
@@ -125,9 +130,13 @@ func (l *logger) ConfigTree(dst []ConfigNode) []ConfigNode {
 	return dst
 }
 
-// Log closes 'o' and if that results in an error 'e', it calls
-// 'config.E(l, e)' where 'config' is the current configuration
-// of 'l'.
+// Log runs the Post middlewares of 'l' and the deferred functions of 'o',
+// and then closes 'o'.  If that results in an error 'e', it calls
+// 'config.E(l, e)' where 'config' is the current configuration of 'l'.
+// Otherwise, 'o' is formatted to the writer of 'l' and to its Sinks.
+// Errors in formatting or writing are given to the corresponding E as
+// *SinkErrors, after 'o' has been given to all of them, as are changes of
+// state of those writers which are StatusReporters.
 func (l *logger) Log(o *Obj) {
 	if l == nil {
 		return
@@ -137,15 +146,26 @@ func (l *logger) Log(o *Obj) {
 	for _, mw := range l.config.Post {
 		o = mw(l.config, o)
 	}
+	if o == nil {
+		return
+	}
+	o.runLazy()
+	objs := sinkObjs(l.config, o)
 	if err := o.Close(); err != nil {
 		if l.config.E != nil {
 			l.config.E(l.config, err)
 		}
 		return
 	}
+	var err error
 	if l.config.F != nil {
-		l.config.F.Fmt(l.config.W, o.D())
+		err = fmtSafe(l.config, l.config.F, l.config.W, o.D())
+	}
+	writeSinks(l.config, objs)
+	if err != nil && l.config.E != nil {
+		l.config.E(l.config, &SinkError{Package: l.config.pkg, Err: err})
 	}
+	reportStatus(l.config, "", l.config.W, l.config.E)
 }
 
 // Walk calls Logger.Walk from the root logger.
@@ -187,6 +207,7 @@ func New(cfg *Config) Logger {
 			pkg = fn[:i]
 		}
 		cfg.pkg = pkg
+		cc.pkg = pkg
 	}
 	return res
 }
@@ -195,7 +216,7 @@ var root = &logger{
 	config: &Config{
 		W: os.Stderr,
 		F: nil,
-		E: EPanic,
+		E: EDefault,
 	},
 }
 
@@ -243,7 +264,7 @@ func (l *logger) obj() *Obj {
 	}
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	res := &Obj{logger: l}
+	res := &Obj{logger: l, opts: l.config.objOpts()}
 	for _, mw := range l.config.Pre {
 		res = mw(l.config, res)
 	}
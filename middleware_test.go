@@ -28,3 +28,32 @@ func Example_pkgMiddleware() {
 	// Output:
 	// {"i":3,"Lpkg":"github.com/scott-cotton/L_test"}
 }
+
+var redactOut = bytes.NewBuffer(nil)
+
+func redact(_ *L.Config, o *L.Obj) *L.Obj {
+	o.Replace("password", L.Redacted)
+	if v, ok := o.Get("debug"); ok && string(v) == "true" {
+		o.Delete("debug")
+	}
+	return o
+}
+
+var redactL = L.New(&L.Config{
+	W:    redactOut,
+	F:    L.JSONFmter(),
+	E:    L.EPanic,
+	Post: []L.Middleware{redact},
+})
+
+func Example_redactMiddleware() {
+	redactL.Dict().
+		Field("user", "bob").
+		Field("password", "secret").
+		Field("debug", true).
+		Log()
+	fmt.Print(redactOut.String())
+
+	// Output:
+	// {"user":"bob","password":"[redacted]"}
+}
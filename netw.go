@@ -0,0 +1,511 @@
+package L
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Framings of a NetWriter.
+const (
+	// FrameNewline ends each record with a newline.
+	FrameNewline = iota
+	// FrameLength prefixes each record, without its trailing newline,
+	// with its length as a 4 byte big-endian integer.
+	FrameLength
+)
+
+// StatusReporter is implemented by writers which report changes in their
+// state, such as a lost connection.  After each object is written, a
+// logger gives the errors returned by Status of the writer of its Config
+// and of its Sinks to the corresponding E, as *SinkErrors.
+type StatusReporter interface {
+	// Status returns the changes since the last call, if any.
+	Status() []error
+}
+
+// NetEvent is a change in the connection state of a NetWriter, reported
+// through StatusReporter.
+type NetEvent struct {
+	Addr string
+	// Up is whether the NetWriter is connected.
+	Up bool
+	// Err is the error which disconnected the NetWriter, if not Up.
+	Err error
+	// Dropped is the number of records dropped from the spool while
+	// disconnected, if Up.
+	Dropped int64
+}
+
+func (e *NetEvent) Error() string {
+	if e.Up {
+		return fmt.Sprintf("L: reconnected to %s, %d records dropped", e.Addr, e.Dropped)
+	}
+	return fmt.Sprintf("L: disconnected from %s: %v", e.Addr, e.Err)
+}
+
+func (e *NetEvent) Unwrap() error {
+	return e.Err
+}
+
+// NetOpts are the options of a NetWriter.
+type NetOpts struct {
+	// Framing is FrameNewline or FrameLength.
+	Framing int
+	// MinBackoff and MaxBackoff bound the exponential backoff between
+	// connection attempts, 100ms and 30s if zero.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Timeout bounds connection attempts and writes, 5s if zero.
+	Timeout time.Duration
+	// SpoolSize is the number of bytes of records kept while
+	// disconnected, 1MiB if zero.  When it is exceeded, the oldest
+	// records are dropped, or with a SpoolFile, new records.
+	SpoolSize int
+	// SpoolFile, if not empty, is a file in which records are kept
+	// while disconnected instead of memory, so that they are sent
+	// after a restart.
+	SpoolFile string
+}
+
+// NetWriter is an io.WriteCloser sending each record written to it to a
+// collector over a "tcp", "udp" or "unix" connection.  Records are queued
+// and sent from a separate goroutine, so writes do not wait for the
+// connection.  While it is disconnected, records are spooled and it
+// reconnects in the background with exponential backoff, sending the
+// spooled records first.  Changes in the connection state, and errors in
+// writing the SpoolFile, are reported as NetEvents through
+// StatusReporter.
+//
+// A NetWriter is safe for concurrent use.
+type NetWriter struct {
+	network, addr string
+	opts          NetOpts
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	conn    net.Conn
+	spool   [][]byte
+	nspool  int
+	file    *os.File
+	nfile   int64
+	dropped int64
+	events  []error
+	pending int32
+	redial  bool
+	closed  bool
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// withDefaults returns 'o' with the defaults of its zero fields.
+func (o NetOpts) withDefaults() NetOpts {
+	if o.MinBackoff <= 0 {
+		o.MinBackoff = 100 * time.Millisecond
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = 30 * time.Second
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 5 * time.Second
+	}
+	if o.SpoolSize <= 0 {
+		o.SpoolSize = 1 << 20
+	}
+	return o
+}
+
+// NewNetWriter creates a NetWriter to 'addr' on 'network', with options
+// 'opts', which may be nil.  It connects in the background.
+func NewNetWriter(network, addr string, opts *NetOpts) (*NetWriter, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6", "udp", "udp4", "udp6", "unix", "unixgram":
+	default:
+		return nil, errors.New("unsupported network: " + network)
+	}
+	w := &NetWriter{network: network, addr: addr, stop: make(chan struct{})}
+	if opts != nil {
+		w.opts = *opts
+	}
+	w.opts = w.opts.withDefaults()
+	if w.opts.SpoolFile != "" {
+		f, err := os.OpenFile(w.opts.SpoolFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		st, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		w.file, w.nfile = f, st.Size()
+	}
+	w.cond = sync.NewCond(&w.mu)
+	w.wg.Add(1)
+	go w.sendLoop()
+	w.mu.Lock()
+	w.reconnect(nil)
+	w.mu.Unlock()
+	return w, nil
+}
+
+func (w *NetWriter) frame(p []byte) []byte {
+	if w.opts.Framing == FrameLength {
+		p = bytes.TrimSuffix(p, []byte{'\n'})
+		res := make([]byte, 4, 4+len(p))
+		binary.BigEndian.PutUint32(res, uint32(len(p)))
+		return append(res, p...)
+	}
+	res := make([]byte, 0, len(p)+1)
+	res = append(res, p...)
+	if len(p) == 0 || p[len(p)-1] != '\n' {
+		res = append(res, '\n')
+	}
+	return res
+}
+
+// Write queues 'p' to be sent, or spools it while disconnected.
+func (w *NetWriter) Write(p []byte) (int, error) {
+	msg := w.frame(p)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, ErrClosed
+	}
+	if w.conn == nil {
+		w.keep(msg)
+		return len(p), nil
+	}
+	w.queue(msg)
+	w.cond.Broadcast()
+	return len(p), nil
+}
+
+// keep spools 'msg' in the SpoolFile, if any, and otherwise in memory.
+func (w *NetWriter) keep(msg []byte) {
+	if w.file == nil {
+		w.queue(msg)
+		return
+	}
+	if w.nfile+int64(len(msg))+4 > int64(w.opts.SpoolSize) {
+		w.dropped++
+		return
+	}
+	rec := make([]byte, 4, 4+len(msg))
+	binary.BigEndian.PutUint32(rec, uint32(len(msg)))
+	rec = append(rec, msg...)
+	if _, err := w.file.Write(rec); err != nil {
+		// remove a partial record.
+		w.file.Truncate(w.nfile)
+		w.dropped++
+		w.event(&NetEvent{Addr: w.addr, Err: fmt.Errorf("spooling: %w", err)})
+		return
+	}
+	w.nfile += int64(len(rec))
+}
+
+// queue appends 'msg' to the records in memory, dropping the oldest ones
+// beyond SpoolSize.
+func (w *NetWriter) queue(msg []byte) {
+	w.spool = append(w.spool, msg)
+	w.nspool += len(msg)
+	i := 0
+	for w.nspool > w.opts.SpoolSize && i < len(w.spool) {
+		w.nspool -= len(w.spool[i])
+		w.dropped++
+		i++
+	}
+	if i > 0 {
+		w.spool = append(w.spool[:0], w.spool[i:]...)
+	}
+}
+
+// reconnect closes the connection, reporting 'err' if it is not nil, and
+// starts reconnecting.
+func (w *NetWriter) reconnect(err error) {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+	if err != nil {
+		w.event(&NetEvent{Addr: w.addr, Err: err})
+	}
+	if w.redial || w.closed {
+		return
+	}
+	w.redial = true
+	w.wg.Add(1)
+	go w.dialLoop(err != nil)
+}
+
+func (w *NetWriter) event(e error) {
+	w.events = append(w.events, e)
+	atomic.StoreInt32(&w.pending, 1)
+}
+
+// dialLoop connects with exponential backoff, waiting first if 'wait'
+// is set.
+func (w *NetWriter) dialLoop(wait bool) {
+	defer w.wg.Done()
+	backoff := w.opts.MinBackoff
+	down := wait
+	for {
+		if wait {
+			select {
+			case <-w.stop:
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > w.opts.MaxBackoff {
+				backoff = w.opts.MaxBackoff
+			}
+		}
+		wait = true
+		c, err := net.DialTimeout(w.network, w.addr, w.opts.Timeout)
+		if err != nil {
+			if !down {
+				w.mu.Lock()
+				w.event(&NetEvent{Addr: w.addr, Err: err})
+				w.mu.Unlock()
+				down = true
+			}
+			continue
+		}
+		w.mu.Lock()
+		if w.closed {
+			w.mu.Unlock()
+			c.Close()
+			return
+		}
+		w.conn = c
+		w.redial = false
+		if down {
+			w.event(&NetEvent{Addr: w.addr, Up: true, Dropped: w.dropped})
+		}
+		w.dropped = 0
+		w.cond.Broadcast()
+		w.mu.Unlock()
+		return
+	}
+}
+
+// sendable returns whether there are records to send on a connection.
+func (w *NetWriter) sendable() bool {
+	return w.conn != nil && (len(w.spool) > 0 || w.nfile > 0)
+}
+
+// sendLoop sends the spooled records, those of the SpoolFile first,
+// while connected, until 'w' is closed.
+func (w *NetWriter) sendLoop() {
+	defer w.wg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for {
+		for !w.sendable() && !w.closed {
+			w.cond.Wait()
+		}
+		if !w.sendable() {
+			return
+		}
+		filed, err := w.readSpoolFile()
+		if err != nil {
+			w.reconnect(err)
+			continue
+		}
+		c, timeout := w.conn, w.opts.Timeout
+		msgs := append(filed, w.spool...)
+		w.spool, w.nspool = nil, 0
+		w.mu.Unlock()
+		i, err := send(c, timeout, msgs)
+		w.mu.Lock()
+		if i >= len(filed) && len(filed) > 0 {
+			if terr := w.file.Truncate(0); terr != nil {
+				w.event(&NetEvent{Addr: w.addr, Err: fmt.Errorf("spooling: %w", terr)})
+			}
+			w.nfile = 0
+		}
+		if err == nil {
+			continue
+		}
+		// spool the unsent records ahead of those written meanwhile.
+		// some records of the SpoolFile may be sent twice.
+		if i < len(filed) {
+			i = len(filed)
+		}
+		later := w.spool
+		w.spool, w.nspool = nil, 0
+		for _, msg := range msgs[i:] {
+			w.keep(msg)
+		}
+		for _, msg := range later {
+			w.keep(msg)
+		}
+		w.reconnect(err)
+	}
+}
+
+// send sends 'msgs' on 'c', returning the number sent.
+func send(c net.Conn, timeout time.Duration, msgs [][]byte) (int, error) {
+	for i, msg := range msgs {
+		c.SetWriteDeadline(time.Now().Add(timeout))
+		if _, err := c.Write(msg); err != nil {
+			return i, err
+		}
+	}
+	return len(msgs), nil
+}
+
+// readSpoolFile reads the records of the SpoolFile.
+func (w *NetWriter) readSpoolFile() ([][]byte, error) {
+	if w.file == nil || w.nfile == 0 {
+		return nil, nil
+	}
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(io.LimitReader(w.file, w.nfile))
+	var res [][]byte
+	var n [4]byte
+	for {
+		if _, err := io.ReadFull(r, n[:]); err != nil {
+			break
+		}
+		msg := make([]byte, binary.BigEndian.Uint32(n[:]))
+		if _, err := io.ReadFull(r, msg); err != nil {
+			break
+		}
+		res = append(res, msg)
+	}
+	return res, nil
+}
+
+func (w *NetWriter) key() string {
+	return w.network + " " + w.addr
+}
+
+// Connected returns whether 'w' is connected.
+func (w *NetWriter) Connected() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.conn != nil
+}
+
+// Status returns the NetEvents since the last call.
+func (w *NetWriter) Status() []error {
+	if atomic.LoadInt32(&w.pending) == 0 {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	res := w.events
+	w.events = nil
+	atomic.StoreInt32(&w.pending, 0)
+	return res
+}
+
+// Close sends the queued records while connected, stops reconnecting
+// and closes the connection and spool file.  Records which are spooled
+// in memory while disconnected are lost.
+func (w *NetWriter) Close() error {
+	netWriters.Lock()
+	if netWriters.m[w.key()] == w {
+		delete(netWriters.m, w.key())
+	}
+	netWriters.Unlock()
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return ErrClosed
+	}
+	w.closed = true
+	close(w.stop)
+	w.cond.Broadcast()
+	w.mu.Unlock()
+	w.wg.Wait()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var err error
+	if w.conn != nil {
+		err = w.conn.Close()
+		w.conn = nil
+	}
+	if w.file != nil {
+		if ferr := w.file.Close(); err == nil {
+			err = ferr
+		}
+		w.file = nil
+	}
+	return err
+}
+
+// reportStatus gives the changes of state of 'wr', the writer of the Sink
+// named 'sink' or of 'cfg' if empty, to 'e' as *SinkErrors, if it is a
+// StatusReporter.
+func reportStatus(cfg *Config, sink string, wr io.Writer, e func(*Config, error)) {
+	sr, ok := wr.(StatusReporter)
+	if !ok {
+		return
+	}
+	errs := sr.Status()
+	if e == nil {
+		return
+	}
+	for _, err := range errs {
+		e(cfg, &SinkError{Package: cfg.pkg, Sink: sink, Err: err})
+	}
+}
+
+// netWriters are the open NetWriters created by the "net" writer, by
+// network and address.  A writer is reused only with the same options.
+var netWriters = struct {
+	sync.Mutex
+	m map[string]*NetWriter
+}{m: map[string]*NetWriter{}}
+
+func init() {
+	RegisterWriter("net", func(p json.RawMessage) (io.Writer, error) {
+		var np struct {
+			Network   string `json:"network"`
+			Addr      string `json:"addr"`
+			Framing   string `json:"framing"`
+			SpoolSize int    `json:"spoolSize"`
+			SpoolFile string `json:"spoolFile"`
+		}
+		if err := DecodeParams(p, &np); err != nil {
+			return nil, err
+		}
+		opts := &NetOpts{SpoolSize: np.SpoolSize, SpoolFile: np.SpoolFile}
+		switch np.Framing {
+		case "", "newline":
+		case "length":
+			opts.Framing = FrameLength
+		default:
+			return nil, fmt.Errorf("unknown framing %q", np.Framing)
+		}
+		key := np.Network + " " + np.Addr
+		netWriters.Lock()
+		defer netWriters.Unlock()
+		if w := netWriters.m[key]; w != nil {
+			if w.opts != opts.withDefaults() {
+				return nil, fmt.Errorf("%s already open with other options", key)
+			}
+			return w, nil
+		}
+		w, err := NewNetWriter(np.Network, np.Addr, opts)
+		if err != nil {
+			return nil, err
+		}
+		netWriters.m[key] = w
+		return w, nil
+	})
+}
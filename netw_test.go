@@ -0,0 +1,237 @@
+package L
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for i := 0; i < 500; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func TestNetWriterReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	nw, err := NewNetWriter("tcp", addr, &NetOpts{MinBackoff: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nw.Close()
+	var mu sync.Mutex
+	var events []error
+	l := New(&Config{
+		W: nw,
+		F: JSONFmter(),
+		E: func(_ *Config, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, err)
+		},
+	})
+	defer l.Close()
+
+	c, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "connection", nw.Connected)
+	l.Dict().Field("a", 1).Log()
+	r := bufio.NewReader(c)
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if ln, err := r.ReadString('\n'); err != nil || ln != `{"a":1}`+"\n" {
+		t.Fatalf("read %q %v", ln, err)
+	}
+
+	// the collector goes away.
+	c.Close()
+	ln.Close()
+	waitFor(t, "disconnection", func() bool {
+		l.Dict().Field("b", 1).Log()
+		return !nw.Connected()
+	})
+	l.Dict().Field("c", 1).Log()
+
+	// and comes back.
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("cannot listen again on %s: %v", addr, err)
+	}
+	defer ln.Close()
+	c, err = ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	r = bufio.NewReader(c)
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		ln, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ln == `{"c":1}`+"\n" {
+			break
+		}
+		if ln != `{"b":1}`+"\n" {
+			t.Fatalf("read %q", ln)
+		}
+	}
+	l.Dict().Field("d", 1).Log()
+	mu.Lock()
+	defer mu.Unlock()
+	var ev *NetEvent
+	if len(events) != 2 || !errors.As(events[0], &ev) || ev.Up || !errors.As(events[1], &ev) || !ev.Up {
+		t.Errorf("events %v", events)
+	}
+}
+
+func TestNetWriterSpoolFile(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "sock")
+	spool := filepath.Join(t.TempDir(), "spool")
+	opts := &NetOpts{Framing: FrameLength, MinBackoff: 10 * time.Millisecond, SpoolFile: spool, SpoolSize: 30}
+	nw, err := NewNetWriter("unix", sock, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "failed connection", func() bool { return len(nw.Status()) == 1 })
+	for _, m := range []string{"a\n", "bb\n", "ccc\n", "dddd\n"} {
+		io.WriteString(nw, m)
+	}
+	// the spool is kept after a restart.
+	nw.Close()
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	nw, err = NewNetWriter("unix", sock, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nw.Close()
+	c, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	io.WriteString(nw, "e\n")
+	var got []string
+	for len(got) < 4 {
+		var n [4]byte
+		if _, err := io.ReadFull(c, n[:]); err != nil {
+			t.Fatal(err)
+		}
+		msg := make([]byte, binary.BigEndian.Uint32(n[:]))
+		if _, err := io.ReadFull(c, msg); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(msg))
+	}
+	if want := []string{"a", "bb", "ccc", "e"}; fmtStrings(got) != fmtStrings(want) {
+		t.Errorf("got %q want %q", got, want)
+	}
+}
+
+func fmtStrings(ss []string) string {
+	res := ""
+	for _, s := range ss {
+		res += s + "|"
+	}
+	return res
+}
+
+func TestNetWriterNoWait(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	nw, err := NewNetWriter("unix", sock, &NetOpts{Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the collector never reads.
+	c, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	waitFor(t, "connection", nw.Connected)
+	rec := make([]byte, 256<<10)
+	start := time.Now()
+	for i := 0; i < 8; i++ {
+		if _, err := nw.Write(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("writes took %s", d)
+	}
+	c.Close()
+	nw.Close()
+}
+
+func TestNetWriterSpoolError(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "sock")
+	spool := filepath.Join(t.TempDir(), "spool")
+	nw, err := NewNetWriter("unix", sock, &NetOpts{SpoolFile: spool})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nw.Close()
+	waitFor(t, "failed connection", func() bool { return len(nw.Status()) == 1 })
+	nw.mu.Lock()
+	nw.file.Close()
+	nw.mu.Unlock()
+	if _, err := io.WriteString(nw, "a\n"); err != nil {
+		t.Fatal(err)
+	}
+	var ev *NetEvent
+	if st := nw.Status(); len(st) != 1 || !errors.As(st[0], &ev) || ev.Up || nw.nfile != 0 {
+		t.Errorf("status %v, %d spooled", st, nw.nfile)
+	}
+}
+
+func TestNetWriterSpec(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "sock")
+	p := []byte(`{"network": "unix", "addr": "` + sock + `"}`)
+	w, err := NewWriter(&Spec{Name: "net", Params: p})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w2, err := NewWriter(&Spec{Name: "net", Params: p}); err != nil || w2 != w {
+		t.Errorf("not reused: %v", err)
+	}
+	lp := []byte(`{"network": "unix", "addr": "` + sock + `", "framing": "length"}`)
+	if _, err := NewWriter(&Spec{Name: "net", Params: lp}); err == nil {
+		t.Errorf("no error for other options")
+	}
+	w.(*NetWriter).Close()
+	w2, err := NewWriter(&Spec{Name: "net", Params: lp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w2.(*NetWriter).Close()
+	if w2 == w {
+		t.Errorf("closed writer reused")
+	}
+}
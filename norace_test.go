@@ -0,0 +1,5 @@
+//go:build !race
+
+package L_test
+
+const raceEnabled = false
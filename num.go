@@ -0,0 +1,164 @@
+package L
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// The policies for NaN and infinite floats, for Config.NonFinite.
+const (
+	// NonFiniteString encodes NaN and infinities as the strings
+	// "NaN", "+Inf" and "-Inf".  It is the default.
+	NonFiniteString = "string"
+	// NonFiniteNull encodes NaN and infinities as null.
+	NonFiniteNull = "null"
+	// NonFiniteError encodes NaN and infinities as null and
+	// records an error, which is returned by Obj.Close.
+	NonFiniteError = "error"
+)
+
+// FloatJSON is the value of Config.FloatFormat formatting floats as
+// by encoding/json.  It is the default, and resets an earlier
+// FloatFormat in Config.Apply.
+const FloatJSON = "json"
+
+// floatOpts are the settings for encoding floats.
+type floatOpts struct {
+	fmt       byte // 0 for the format of encoding/json
+	prec      int
+	nonFinite string
+}
+
+func (c *Config) floatOpts() floatOpts {
+	res := floatOpts{prec: -1, nonFinite: c.NonFinite}
+	switch c.FloatFormat {
+	case "e", "f", "g":
+		res.fmt = c.FloatFormat[0]
+		if c.FloatPrec > 0 {
+			res.prec = c.FloatPrec
+		}
+	}
+	return res
+}
+
+func (t *Obj) float(v float64, bits int) *Obj {
+	if t == nil {
+		return nil
+	}
+	r := t.getRoot()
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return t.nonFinite(v)
+	}
+	if t.hadChild {
+		t.WriteByte(',')
+	}
+	start := len(r.d)
+	r.d = appendFloat(r.d, v, bits, r.opts.float)
+	t.validated(start, false)
+	t.hadChild = true
+	return t
+}
+
+// nonFinite encodes the NaN or infinite value 'v' according to the
+// NonFinite policy of 't'.
+func (t *Obj) nonFinite(v float64) *Obj {
+	switch t.getRoot().opts.float.nonFinite {
+	case NonFiniteNull:
+		return t.Null()
+	case NonFiniteError:
+		t.fail(fmt.Errorf("unsupported float value: %v", v))
+		return t.Null()
+	}
+	return t.Str(strconv.FormatFloat(v, 'g', -1, 64))
+}
+
+// appendFloat appends the encoding of the finite float 'v' of size
+// 'bits' according to 'opts'.
+func appendFloat(d []byte, v float64, bits int, opts floatOpts) []byte {
+	if opts.fmt != 0 {
+		return strconv.AppendFloat(d, v, opts.fmt, opts.prec, bits)
+	}
+	// as encoding/json: the shortest representation, with an exponent
+	// only for very large or small values.
+	f := byte('f')
+	if abs := math.Abs(v); abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) ||
+			bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			f = 'e'
+		}
+	}
+	start := len(d)
+	d = strconv.AppendFloat(d, v, f, -1, bits)
+	if f == 'e' {
+		// clean up e-09 to e-9
+		n := len(d) - start
+		if n >= 4 && d[len(d)-4] == 'e' && d[len(d)-3] == '-' && d[len(d)-2] == '0' {
+			d[len(d)-2] = d[len(d)-1]
+			d = d[:len(d)-1]
+		}
+	}
+	return d
+}
+
+// BigInt encodes 'v' as a json number with all its digits.
+func (t *Obj) BigInt(v *big.Int) *Obj {
+	if t == nil {
+		return nil
+	}
+	if v == nil {
+		return t.Null()
+	}
+	if t.hadChild {
+		t.WriteByte(',')
+	}
+	r := t.getRoot()
+	start := len(r.d)
+	r.d = v.Append(r.d, 10)
+	t.validated(start, false)
+	t.hadChild = true
+	return t
+}
+
+// BigFloat encodes 'v' as a json number with the precision of 'v'.  If
+// Config.FloatFormat is set, it determines the format as for Float.  An
+// infinite 'v' is encoded according to Config.NonFinite.
+func (t *Obj) BigFloat(v *big.Float) *Obj {
+	if t == nil {
+		return nil
+	}
+	if v == nil {
+		return t.Null()
+	}
+	if v.IsInf() {
+		return t.nonFinite(math.Inf(v.Sign()))
+	}
+	if t.hadChild {
+		t.WriteByte(',')
+	}
+	r := t.getRoot()
+	start := len(r.d)
+	if f := r.opts.float; f.fmt != 0 {
+		r.d = v.Append(r.d, f.fmt, f.prec)
+	} else {
+		r.d = v.Append(r.d, 'g', -1)
+	}
+	t.validated(start, false)
+	t.hadChild = true
+	return t
+}
+
+// Number encodes 'v' as a json number, verbatim.  As in encoding/json,
+// the empty Number is encoded as 0.  Unless validation is disabled, an
+// invalid number results in an error returned by Close.
+func (t *Obj) Number(v json.Number) *Obj {
+	if t == nil {
+		return nil
+	}
+	if v == "" {
+		v = "0"
+	}
+	return t.raw([]byte(v))
+}
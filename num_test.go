@@ -0,0 +1,100 @@
+package L
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func FuzzFloat(f *testing.F) {
+	for _, v := range []float64{0, 1, -1, 22, 2.5, 1e-7, 1e-6, 1e20, 1e21, 123456789.125, math.MaxFloat64, math.SmallestNonzeroFloat64} {
+		f.Add(v)
+	}
+	f.Fuzz(func(t *testing.T, v float64) {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return
+		}
+		xs := []any{v}
+		if !math.IsInf(float64(float32(v)), 0) {
+			xs = append(xs, float32(v))
+		}
+		for _, x := range xs {
+			o := (&Obj{}).Any(x)
+			if err := o.Close(); err != nil {
+				t.Fatalf("%v: %v", x, err)
+			}
+			exp, _ := json.Marshal(x)
+			if got := string(o.D()); got != string(exp) {
+				t.Fatalf("%T %v: got %s exp %s", x, x, got, exp)
+			}
+		}
+	})
+}
+
+func TestFloatConfig(t *testing.T) {
+	for _, tc := range []struct {
+		cfg  Config
+		v    any
+		want string
+		err  bool
+	}{
+		{Config{}, 22.0, `22`, false},
+		{Config{FloatFormat: "e"}, 22.0, `2.2e+01`, false},
+		{Config{FloatFormat: "f", FloatPrec: 2}, 1.0 / 3, `0.33`, false},
+		{Config{FloatFormat: "g", FloatPrec: 3}, 1234.5, `1.23e+03`, false},
+		{Config{FloatFormat: "x"}, 0.5, `0.5`, false},
+		{Config{FloatFormat: FloatJSON, FloatPrec: 2}, 1.0 / 4, `0.25`, false},
+		{Config{}, math.NaN(), `"NaN"`, false},
+		{Config{}, math.Inf(-1), `"-Inf"`, false},
+		{Config{}, float32(math.Inf(1)), `"+Inf"`, false},
+		{Config{NonFinite: NonFiniteNull}, math.Inf(1), `null`, false},
+		{Config{NonFinite: NonFiniteError}, math.NaN(), `null`, true},
+		{Config{}, new(big.Int).Lsh(big.NewInt(1), 100), `1267650600228229401496703205376`, false},
+		{Config{}, (*big.Int)(nil), `null`, false},
+		{Config{}, big.NewFloat(1.25), `1.25`, false},
+		{Config{FloatFormat: "f", FloatPrec: 1}, big.NewFloat(1.25), `1.2`, false},
+		{Config{NonFinite: NonFiniteNull}, new(big.Float).SetInf(true), `null`, false},
+		{Config{}, json.Number("12345678901234567890.123"), `12345678901234567890.123`, false},
+		{Config{}, json.Number(""), `0`, false},
+		{Config{}, json.Number("1x"), `1x`, true},
+	} {
+		o := &Obj{opts: tc.cfg.objOpts()}
+		a := o.Array().Any(tc.v)
+		err := a.Close()
+		if err == nil {
+			err = o.Close()
+		}
+		if (err != nil) != tc.err {
+			t.Errorf("%v: err %v", tc.v, err)
+		}
+		if got := string(o.D()); got != "["+tc.want+"]" {
+			t.Errorf("%v: got %s want [%s]", tc.v, got, tc.want)
+		}
+	}
+}
+
+func TestFloatApply(t *testing.T) {
+	c := &Config{}
+	for _, tc := range []struct {
+		mod  string
+		want string
+	}{
+		{`{"floatFormat":"f"}`, `0.3333333333333333`},
+		{`{"floatPrec":2}`, `0.33`},
+		{`{"labels":{"a":1}}`, `0.33`},
+		{`{"floatFormat":"e"}`, `3.33e-01`},
+		{`{"floatPrec":-1}`, `3.333333333333333e-01`},
+		{`{"floatFormat":"json"}`, `0.3333333333333333`},
+	} {
+		var o Config
+		if err := json.Unmarshal([]byte(tc.mod), &o); err != nil {
+			t.Fatal(err)
+		}
+		c.Apply(&o, nil)
+		d := (&Obj{opts: c.Clone().objOpts()}).Float(1.0 / 3).D()
+		if got := string(d); got != tc.want {
+			t.Errorf("after %s: got %s want %s", tc.mod, got, tc.want)
+		}
+	}
+}
@@ -2,12 +2,15 @@ package L
 
 import (
 	"bytes"
+	"encoding"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"os"
+	"reflect"
 	"strconv"
-	"unicode/utf8"
+	"time"
 )
 
 // Obj represents a thing to be logged, with structured logging in mind.
@@ -39,19 +42,35 @@ import (
 type Obj struct {
 	parent   *Obj
 	root     *Obj
-	hasChild bool
+	child    *Obj
 	hadChild bool
-	d        []byte
-	i        int
-	logger   Logger
-}
-
-func (t *Obj) mkChild() *Obj {
-	if t.hasChild == true {
+	// kind is '{' or '[' for Objs created by Dict or Array,
+	// and 0 for roots.
+	kind   byte
+	closed bool
+	d      []byte
+	i      int
+	logger Logger
+	// err is the first error encountered in the tree, kept
+	// in the root.
+	err error
+	// v validates the buffer of a root.
+	v    validator
+	opts objOpts
+	// lazy holds the deferred functions of the tree, in
+	// order of position.
+	lazy []lazy
+	// pending holds the deferred functions added to a root
+	// before it had a value.
+	pending []func(*Obj)
+}
+
+func (t *Obj) mkChild(kind byte) *Obj {
+	if t.child != nil {
 		return nil
 	}
-	res := &Obj{parent: t, i: len(*t.buf())}
-	t.hasChild = true
+	res := &Obj{parent: t, i: len(*t.buf()), kind: kind}
+	t.child = res
 	return res
 }
 
@@ -71,6 +90,15 @@ func (t *Obj) buf() *[]byte {
 	return &t.getRoot().d
 }
 
+// fail records the error 'e' in the tree of 't' if no
+// error has yet been recorded.
+func (t *Obj) fail(e error) {
+	r := t.getRoot()
+	if r.err == nil {
+		r.err = e
+	}
+}
+
 // Clone creates a clone of 't' which can be manipulated
 // independently of 't'.
 func (t *Obj) Clone() *Obj {
@@ -81,14 +109,29 @@ func (t *Obj) Clone() *Obj {
 	if t.parent != nil {
 		p = t.parent.Clone()
 	}
-	res := &Obj{parent: p, i: t.i, hasChild: t.hasChild}
+	res := &Obj{
+		parent:   p,
+		i:        t.i,
+		kind:     t.kind,
+		closed:   t.closed,
+		hadChild: t.hadChild,
+	}
+	if p != nil && t.parent.child == t {
+		p.child = res
+	}
 	res.root = res.getRoot()
-	d := *t.buf()
 
 	if res.IsRoot() {
+		d := *t.buf()
 		dd := make([]byte, len(d))
 		copy(dd, d)
 		res.d = dd
+		res.logger = t.logger
+		res.err = t.err
+		res.v = t.v.clone()
+		res.opts = t.opts
+		res.lazy = append([]lazy(nil), t.lazy...)
+		res.pending = append(([]func(*Obj))(nil), t.pending...)
 	}
 	return res
 }
@@ -97,15 +140,18 @@ func (t *Obj) Dict() *Obj {
 	if t == nil {
 		return nil
 	}
-	if t.hasChild {
+	if t.child != nil {
 		panic("nonlinear")
 	}
 	if t.hadChild {
 		t.WriteByte(',')
 	}
-	c := t.mkChild()
+	c := t.mkChild('{')
 	c.WriteByte('{')
 	t.hadChild = true
+	if t.IsRoot() && t.pending != nil {
+		c.startPending()
+	}
 	return c
 }
 
@@ -113,70 +159,247 @@ func (t *Obj) Array() *Obj {
 	if t == nil {
 		return nil
 	}
-	if t.hasChild {
+	if t.child != nil {
 		panic("nonlinear")
 	}
 	if t.hadChild {
 		t.WriteByte(',')
 	}
-	c := t.mkChild()
+	c := t.mkChild('[')
 	c.WriteByte('[')
 	t.hadChild = true
 	return c
 }
 
-// Err calls t.Field("Lerr", e.Error())
-func (t *Obj) Err(e error) *Obj {
-	return t.Field("Lerr", e.Error())
-}
-
-// Errf returns t.Err(fmt.Errorf(msgFmt, vs...))
-func (t *Obj) Errf(msgFmt string, vs ...any) *Obj {
-	return t.Err(fmt.Errorf(msgFmt, vs...))
-}
-
-// Field sets a field with key 's' to value 'v'.  'v' must be a bool, int,
-// string, float64, or *Obj or implement json.Marshaler.  If 'v' implements
-// json.Marshaler, and json.Marshal(v) returns an error 'e', Field panics with
-// 'panic(e)'.
+// Field sets a field with key 's' to value 'v'.  'v' is encoded as
+// with Any.
 func (t *Obj) Field(s string, v any) *Obj {
 	if t == nil {
 		return nil
 	}
+	t.key(s).Any(v)
+	t.hadChild = true
+	return t
+}
+
+// key writes the key 's' of a field, leaving 't' ready
+// for its value.
+func (t *Obj) key(s string) *Obj {
 	if t.hadChild {
 		t.WriteByte(',')
 	}
 	t.hadChild = false
 	t.Str(s).WriteByte(':')
 	t.hadChild = false
+	return t
+}
+
+// Any encodes 'v'.  The following types are encoded directly.
+//
+//   - nil, bool, string, []byte and *Obj.
+//   - all integer and unsigned integer types, float32 and float64, as
+//     numbers formatted according to Config.FloatFormat and
+//     Config.FloatPrec.  NaN and infinities are encoded according to
+//     Config.NonFinite.
+//   - json.Number, *big.Int and *big.Float, as numbers without loss of
+//     precision.
+//   - time.Time, as an RFC3339Nano string.
+//   - time.Duration, as a string in the form of time.Duration.String.
+//
+// Otherwise, the first of the following interfaces implemented by 'v'
+// determines the encoding.
+//
+//   - json.Marshaler.  If MarshalJSON returns an error 'e', Any panics with
+//     'panic(e)'.
+//   - encoding.TextMarshaler, as a string, or the error message if
+//     MarshalText fails.
+//   - error, as a string with Error().
+//   - fmt.Stringer, as a string with String().
+//
+// A nil pointer implementing one of these interfaces is encoded as null.
+//
+// Any other value, such as a struct, map or slice, is encoded by
+// reflection, in accordance with 'L' struct tags.  The format of the tags
+// is
+//
+//	`L:"name,opt1,opt2,..."`
+//
+// where the name may be empty, in which case the Go field name is used, or
+// '-', in which case the field is not encoded.  Options may be any of
+//
+//   - omitempty: the field is omitted if it is empty as in encoding/json.
+//   - redact: the value of the field is written as the string Redacted.
+//   - inline: the fields of a struct or map valued field are written
+//     in the enclosing object.
+//
+// A field without an 'L' tag is encoded according to the name and
+// omitempty option of its 'json' tag, if any.  Embedded structs without a
+// name in their tag are inlined.  Map keys are sorted.  Values with no json
+// representation, such as channels and functions, are encoded as strings
+// formatted with the '%v' verb of package fmt.
+//
+// Encoders for each type are computed once and cached.
+func (t *Obj) Any(v any) *Obj {
+	if t == nil {
+		return nil
+	}
 	switch x := v.(type) {
+	case nil:
+		return t.Null()
 	case bool:
-		t.Bool(x)
-	case int:
-		t.Int(x)
+		return t.Bool(x)
 	case string:
-		t.Str(x)
-	case float64:
-		t.Float(x)
+		return t.Str(x)
 	case []byte:
-		t.Bytes(x)
+		return t.Bytes(x)
 	case *Obj:
-		d := t.buf()
-		*d = append(*d, *x.buf()...)
-	default:
-		if jm, ok := v.(json.Marshaler); ok {
-			m, err := jm.MarshalJSON()
-			if err != nil {
-				panic(err)
-			}
-			d := t.buf()
-			*d = append(*d, m...)
+		if x == nil {
+			return t.Null()
 		}
+		return t.splice(x)
+	case int:
+		return t.Int64(int64(x))
+	case int8:
+		return t.Int64(int64(x))
+	case int16:
+		return t.Int64(int64(x))
+	case int32:
+		return t.Int64(int64(x))
+	case int64:
+		return t.Int64(x)
+	case uint:
+		return t.Uint64(uint64(x))
+	case uint8:
+		return t.Uint64(uint64(x))
+	case uint16:
+		return t.Uint64(uint64(x))
+	case uint32:
+		return t.Uint64(uint64(x))
+	case uint64:
+		return t.Uint64(x)
+	case uintptr:
+		return t.Uint64(uint64(x))
+	case float32:
+		return t.float(float64(x), 32)
+	case float64:
+		return t.Float(x)
+	case json.Number:
+		return t.Number(x)
+	case time.Time:
+		return t.Str(x.Format(time.RFC3339Nano))
+	case time.Duration:
+		return t.Str(x.String())
+	}
+	if isNilPtr(v) {
+		switch v.(type) {
+		case json.Marshaler, encoding.TextMarshaler, error, fmt.Stringer:
+			return t.Null()
+		}
+	}
+	switch x := v.(type) {
+	case *big.Int:
+		return t.BigInt(x)
+	case *big.Float:
+		return t.BigFloat(x)
+	case json.Marshaler:
+		m, err := x.MarshalJSON()
+		if err != nil {
+			panic(err)
+		}
+		return t.raw(m)
+	case encoding.TextMarshaler:
+		m, err := x.MarshalText()
+		if err != nil {
+			return t.Str(err.Error())
+		}
+		return t.Str(string(m))
+	case error:
+		return t.Str(x.Error())
+	case fmt.Stringer:
+		return t.Str(x.String())
 	}
+	return t.reflect(v)
+}
+
+// Set sets the field with key 'key' to the value built in 'v'.  'v' should
+// belong to a tree of Objs independent of that of 't', for example one
+// created by a different call to Logger.Dict or Logger.Array.  Set closes
+// all open Objs in the tree of 'v', validates the result, and copies it into
+// 't'.  Any error is returned by the next call to 't.Close'.  Afterwards, 'v'
+// remains independent of 't'.
+//
+// If 'v' is nil, Set does nothing.  If 'v' belongs to the tree of 't', Set
+// panics.
+//
+// Set provides a means to build structures where a parent has several
+// children under construction at the same time.
+func (t *Obj) Set(key string, v *Obj) *Obj {
+	if t == nil || v == nil {
+		return t
+	}
+	return t.key(key).splice(v)
+}
+
+// splice closes the tree of 'v' and appends the resulting value to 't'.
+func (t *Obj) splice(v *Obj) *Obj {
+	r := v.getRoot()
+	if r == t.getRoot() {
+		panic("nonlinear")
+	}
+	if err := r.closeTree(); err != nil {
+		t.fail(err)
+		return t.Null()
+	}
+	if t.hadChild {
+		t.WriteByte(',')
+	}
+	d := t.buf()
+	start := len(*d)
+	*d = append(*d, r.d...)
+	t.validated(start, false)
+	tr := t.getRoot()
+	for _, l := range r.lazy {
+		l.at += start
+		l.pos += start
+		tr.lazy = append(tr.lazy, l)
+	}
+	t.hadChild = true
+	return t
+}
+
+// closeTree closes all open Objs in the tree of 't', from the
+// most deeply nested upwards, returning the first error.
+func (t *Obj) closeTree() error {
+	o := t.getRoot()
+	for o.child != nil {
+		o = o.child
+	}
+	for ; o != nil; o = o.parent {
+		if err := o.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// raw appends the encoded json value 'm'.
+func (t *Obj) raw(m []byte) *Obj {
+	if t.hadChild {
+		t.WriteByte(',')
+	}
+	d := t.buf()
+	*d = append(*d, m...)
 	t.hadChild = true
 	return t
 }
 
+func isNilPtr(v any) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Pointer && rv.IsNil()
+}
+
+// Str creates a string object.  The encoding of 's' is identical to that
+// of encoding/json, with HTML escaping if Config.EscapeHTML is set.
 func (t *Obj) Str(s string) *Obj {
 	if t == nil {
 		return nil
@@ -184,32 +407,10 @@ func (t *Obj) Str(s string) *Obj {
 	if t.hadChild {
 		t.WriteByte(',')
 	}
-	d := *t.buf()
-	d = append(d, '"')
-	for _, r := range s {
-		if r == utf8.RuneError {
-			d = append(d, []byte(`\ufffd`)...)
-			continue
-		}
-		switch r {
-		case '"', '\\':
-			d = append(d, '\\', byte(r))
-		case '\b':
-			d = append(d, '\\', 'b')
-		case '\f':
-			d = append(d, '\\', 'f')
-		case '\n':
-			d = append(d, '\\', 'n')
-		case '\t':
-			d = append(d, '\\', 't')
-		case '\r':
-			d = append(d, '\\', 'r')
-		default:
-			d = utf8.AppendRune(d, r)
-		}
-	}
-	d = append(d, '"')
-	*t.buf() = d
+	r := t.getRoot()
+	start := len(r.d)
+	r.d = appendString(r.d, s, r.opts.escapeHTML)
+	t.validated(start, true)
 	t.hadChild = true
 	return t
 }
@@ -227,16 +428,20 @@ func (t *Obj) Bool(v bool) *Obj {
 	if t.hadChild {
 		t.WriteByte(',')
 	}
-	if v {
-		t.Write([]byte("true"))
-		return t
-	}
-	t.Write([]byte("false"))
+	d := t.buf()
+	start := len(*d)
+	*d = strconv.AppendBool(*d, v)
+	t.validated(start, false)
 	t.hadChild = true
 	return t
 }
 
 func (t *Obj) Int(i int) *Obj {
+	return t.Int64(int64(i))
+}
+
+// Int64 creates an integer object.
+func (t *Obj) Int64(i int64) *Obj {
 	if t == nil {
 		return nil
 	}
@@ -244,12 +449,15 @@ func (t *Obj) Int(i int) *Obj {
 		t.WriteByte(',')
 	}
 	d := t.buf()
-	*d = strconv.AppendInt(*d, int64(i), 10)
+	start := len(*d)
+	*d = strconv.AppendInt(*d, i, 10)
+	t.validated(start, false)
 	t.hadChild = true
 	return t
 }
 
-func (t *Obj) Float(v float64) *Obj {
+// Uint64 creates an unsigned integer object.
+func (t *Obj) Uint64(u uint64) *Obj {
 	if t == nil {
 		return nil
 	}
@@ -257,11 +465,17 @@ func (t *Obj) Float(v float64) *Obj {
 		t.WriteByte(',')
 	}
 	d := t.buf()
-	*d = strconv.AppendFloat(*d, v, 'e', -1, 64)
+	start := len(*d)
+	*d = strconv.AppendUint(*d, u, 10)
+	t.validated(start, false)
 	t.hadChild = true
 	return t
 }
 
+func (t *Obj) Float(v float64) *Obj {
+	return t.float(v, 64)
+}
+
 func Float(v float64) *Obj {
 	res := &Obj{}
 	return res.Float(v)
@@ -275,17 +489,20 @@ func (t *Obj) Bytes(d []byte) *Obj {
 	if t.hadChild {
 		t.WriteByte(',')
 	}
-	t.WriteByte('"')
 	r := t.buf()
-	encLen := base64.StdEncoding.EncodedLen(len(d))
+	start := len(*r)
+	*r = append(*r, '"')
 	n := len(*r)
-	m := n + encLen
+	m := n + base64.StdEncoding.EncodedLen(len(d))
 	if cap(*r) < m {
-		tmp := make([]byte, m, m+n*2)
+		tmp := make([]byte, n, m+m/2)
+		copy(tmp, *r)
 		*r = tmp
 	}
-	base64.StdEncoding.Encode(*r, d)
-	t.WriteByte('"')
+	*r = (*r)[:m]
+	base64.StdEncoding.Encode((*r)[n:], d)
+	*r = append(*r, '"')
+	t.validated(start, true)
 	t.hadChild = true
 	return t
 }
@@ -306,8 +523,10 @@ func (t *Obj) Log() {
 	r.logger.Log(t)
 }
 
+// Fatal logs the object, flushes Async writers and exits with status 1.
 func (t *Obj) Fatal() {
 	t.Log()
+	flushBeforeExit()
 	os.Exit(1)
 }
 
@@ -338,8 +557,13 @@ func (t *Obj) Null() *Obj {
 	if t == nil {
 		return nil
 	}
+	if t.hadChild {
+		t.WriteByte(',')
+	}
 	r := t.buf()
-	*r = append(*r, []byte("null")...)
+	start := len(*r)
+	*r = append(*r, "null"...)
+	t.validated(start, false)
 	t.hadChild = true
 	return t
 }
@@ -390,194 +614,41 @@ func (t *Obj) Parent() *Obj {
 	return t.parent
 }
 
-// Close is a noop unless 't' was created with 'Dict' or 'Array' or
-// 'Logger.Dict'.  In these cases, close validates the json output,
-// returning any error.
+// Close closes 't' if it was created with 'Dict' or 'Array' or
+// 'Logger.Dict', and then returns any error in the json constructed
+// so far, as a *ValidationError.  If 't' is at the start of its tree, Close
+// also checks that the json is complete.
+//
+// Validation is incremental: values written by the methods of Obj are
+// accepted in constant time and other bytes, such as those written by
+// Write, are scanned only once.  Validation can be disabled with
+// Config.NoValidate.
 //
 // Note that Close is called by 'Logger.Log', so it is only necessary
 // on roots created by calls to 'Dict' or 'Array'.
+//
+// Once 't' is closed, its parent may create another child.  Closing
+// 't' more than once has no effect.
 func (t *Obj) Close() error {
 	if t == nil {
 		return nil
 	}
-	d := *t.buf()
-	switch d[t.i] {
-	case '{':
-		d = append(d, '}')
-	case '[':
-		d = append(d, ']')
-	default:
-		if t.parent != nil {
-			return fmt.Errorf("invalid buffer")
-		}
+	if t.closed {
+		return t.getRoot().err
 	}
-	*t.buf() = d
-	if !json.Valid(d) {
-		var v any
-		return json.Unmarshal(*t.buf(), &v)
-	}
-	return nil
-}
-
-func (t *Obj) _() error {
 	d := *t.buf()
-	switch d[t.i] {
+	switch t.kind {
 	case '{':
 		d = append(d, '}')
 	case '[':
 		d = append(d, ']')
-	default:
-		return fmt.Errorf("not a parent")
 	}
 	*t.buf() = d
-	n, e := ck(d, t.i)
-	if e != nil {
-		return e
-	}
-	if n != len(d) {
-		return fmt.Errorf("trailing characters at offset %d", n)
-	}
-	return nil
-}
-
-func ck(d []byte, i int) (n int, err error) {
-	if len(d) == i {
-		return i, nil
-	}
-	fmt.Printf("check %s %d\n", string(d), i)
-	c := d[i]
-	switch d[i] {
-	case '{':
-		n, err = ckObj(d, i+1)
-	case '[':
-		n, err = ckArray(d, i+1)
-	case '"':
-		n, err = ckStr(d, i+1)
-	case 'n':
-		n, err = ckNull(d, i+1)
-	case 't':
-		n, err = ckTrue(d, i+1)
-	case 'f':
-		n, err = ckFalse(d, i+1)
-	default:
-		if c >= '0' && c <= '9' || c == '-' {
-			n, err = ckNumber(d, i)
-			return
-		}
-		return i, fmt.Errorf("unexpected %c at offset %d", d[i], i)
-	}
-	return
-}
-
-func ckObj(d []byte, i int) (j int, err error) {
-	switch d[i] {
-	case '"':
-	key:
-		j, err = ckStr(d, i+1)
-		if err != nil {
-			return 0, err
-		}
-		if j == len(d) {
-			return 0, fmt.Errorf("expected key, got end at %d", j)
-		}
-		if d[j] != ':' {
-			return 0, fmt.Errorf("expected ':' at %d, got %c", j, d[j])
+	if t.kind != 0 {
+		t.closed = true
+		if t.parent.child == t {
+			t.parent.child = nil
 		}
-		j, err = ck(d, j)
-		if err != nil {
-			return 0, err
-		}
-		if j == len(d) {
-			return 0, fmt.Errorf("expected '}' or ',' at %d", j)
-		}
-		switch d[j] {
-		case ',':
-			i = j + 1
-			if i == len(d) {
-				return 0, fmt.Errorf("unexpected end")
-			}
-			if d[i] == '"' {
-				goto key
-			}
-		case '}':
-			return j + 1, nil
-		}
-	case '}':
-		return i + 1, nil
-	default:
-		return 0, fmt.Errorf("unexpected character at %d", i)
-	}
-	return
-}
-func ckArray(d []byte, i int) (j int, err error) {
-	if d[i] == ']' {
-		j = i
-		return
-	}
-value:
-	j, err = ck(d, i)
-	if err != nil {
-		return 0, err
-	}
-	if j == len(d) {
-		return 0, fmt.Errorf("unexpected end")
-	}
-	switch d[j] {
-	case ',':
-		i = j + 1
-		goto value
-	case ']':
-		j++
-		return
-	default:
-		return 0, fmt.Errorf("unexpected %c at %d", d[j], j)
-	}
-	return
-}
-func ckStr(d []byte, i int) (j int, err error) {
-	fmt.Printf("check str at %s", d[i:])
-	for k, b := range d[i:] {
-		if b == '"' {
-			return i + k + 1, nil
-		}
-	}
-	return i, fmt.Errorf("unclosed '\"' at %d", i)
-}
-func ckNumber(d []byte, i int) (j int, err error) {
-	if d[i] == '-' {
-		i++
-		if i == len(d) {
-			return 0, fmt.Errorf("unexpected end")
-		}
-	}
-	j = i
-	for j < len(d) {
-		switch {
-		case d[j] >= '0' && d[j] <= '9':
-		case d[j] == '.' || d[j] == 'e':
-		default:
-			return 0, fmt.Errorf("unexpected %c at %d", d[j], j)
-		}
-		j++
-	}
-
-	return
-}
-func ckTrue(d []byte, i int) (j int, err error) {
-	if bytes.HasPrefix(d[i:], []byte("rue")) {
-		return i + 3, nil
-	}
-	return i, fmt.Errorf("expected true at %d", i)
-}
-func ckFalse(d []byte, i int) (j int, err error) {
-	if bytes.HasPrefix(d[i:], []byte("alse")) {
-		return i + 4, nil
-	}
-	return i, fmt.Errorf("expected false at %d", i)
-}
-func ckNull(d []byte, i int) (j int, err error) {
-	if bytes.HasPrefix(d[i:], []byte("ull")) {
-		return i + 3, nil
 	}
-	return i, fmt.Errorf("expected null at %d", i)
+	return t.validate(t.i == 0)
 }
@@ -1,8 +1,11 @@
 package L_test
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+
+	"github.com/scott-cotton/L"
 )
 
 func Example_obj() {
@@ -37,3 +40,23 @@ func Example_twoChildrenPanic() {
 	// Output:
 	// panic nonlinear
 }
+
+var setW = bytes.NewBuffer(nil)
+
+var setL = L.New(&L.Config{
+	W: setW,
+	F: L.JSONFmter(),
+	E: L.EPanic,
+})
+
+func Example_set() {
+	a := setL.Array().Str("a").Int(4)
+	// b has an open child, which Set closes.
+	b := setL.Array()
+	b.Dict().Field("x", 1)
+	setL.Dict().Field("k", 3).Set("k2", a).Set("k3", b).Log()
+	fmt.Print(setW.String())
+
+	// Output:
+	// {"k":3,"k2":["a",4],"k3":[{"x":1}]}
+}
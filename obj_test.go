@@ -1,7 +1,102 @@
 package L
 
-import "testing"
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
 
 func TestObjGround(t *testing.T) {
 
 }
+
+type stringer struct{}
+
+func (stringer) String() string { return "stringer" }
+
+type point struct {
+	X, Y int
+}
+
+func TestFieldTypes(t *testing.T) {
+	var nilStringer *net.IP
+	tm := time.Date(2022, 7, 1, 10, 11, 12, 13, time.UTC)
+	for _, tc := range []struct {
+		v    any
+		want string
+	}{
+		{nil, `null`},
+		{true, `true`},
+		{false, `false`},
+		{"s", `"s"`},
+		{[]byte("hello"), `"aGVsbG8="`},
+		{int8(-8), `-8`},
+		{int16(-16), `-16`},
+		{int32(-32), `-32`},
+		{int64(-1 << 62), `-4611686018427387904`},
+		{uint(7), `7`},
+		{uint8(8), `8`},
+		{uint16(16), `16`},
+		{uint32(32), `32`},
+		{uint64(1<<64 - 1), `18446744073709551615`},
+		{uintptr(3), `3`},
+		{tm, `"2022-07-01T10:11:12.000000013Z"`},
+		{1500 * time.Millisecond, `"1.5s"`},
+		{errors.New("oops"), `"oops"`},
+		{stringer{}, `"stringer"`},
+		{net.IPv4(127, 0, 0, 1), `"127.0.0.1"`},
+		{nilStringer, `null`},
+		{point{1, 2}, `{"X":1,"Y":2}`},
+		{make(chan int), ``},
+	} {
+		o := (&Obj{}).Dict().Field("k", tc.v)
+		if err := o.Close(); err != nil {
+			t.Errorf("%T: %v", tc.v, err)
+			continue
+		}
+		want := `{"k":` + tc.want + `}`
+		if tc.want == "" {
+			// fallback to %v, which is not deterministic for channels.
+			continue
+		}
+		if got := string(o.D()); got != want {
+			t.Errorf("%T: got %s want %s", tc.v, got, want)
+		}
+	}
+}
+
+func TestArrayValues(t *testing.T) {
+	o := (&Obj{}).Array().Bool(true).Bool(false).Null().Int64(-1).Uint64(1).Bytes([]byte{0xff})
+	if err := o.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := `[true,false,null,-1,1,"/w=="]`
+	if got := string(o.D()); got != want {
+		t.Errorf("got %s want %s", got, want)
+	}
+}
+
+func TestCloseSiblings(t *testing.T) {
+	a := (&Obj{}).Array()
+	if err := a.Dict().Field("x", 1).Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Dict().Field("y", 2).Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"x":1},{"y":2}]`
+	if got := string(a.D()); got != want {
+		t.Errorf("got %s want %s", got, want)
+	}
+}
+
+func TestSetInvalid(t *testing.T) {
+	d := (&Obj{}).Dict().Set("k", (&Obj{}).Str("a").Str("b"))
+	if err := d.Close(); err == nil {
+		t.Errorf("expected error, got %s", d.D())
+	}
+}
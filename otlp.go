@@ -0,0 +1,583 @@
+package L
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// OTLPSeverity associates an OpenTelemetry severity number and text with
+// the values of a severity label of at least Min.
+type OTLPSeverity struct {
+	Min    int    `json:"min,omitempty"`
+	Number int    `json:"number,omitempty"`
+	Text   string `json:"text,omitempty"`
+}
+
+// OTLPFmter is a Fmter writing dicts as OpenTelemetry LogRecords in the
+// OTLP/JSON encoding.  Each dict is written on a line as an
+// ExportLogsServiceRequest with a single LogRecord, as accepted by the
+// otlpjsonfile receiver of the collector, or by OTLPWriter for sending in
+// batches.
+//
+// The fields of the dict are placed in the LogRecord as follows.
+//
+//   - the RFC3339 time with key TimeKey becomes timeUnixNano.
+//   - the value with key BodyKey becomes the body.
+//   - the hex ids with keys TraceIDKey and SpanIDKey become the traceId
+//     and spanId.
+//   - if SeverityKey is set, the value of the field with that key, such
+//     as a label added by the Label middleware, selects the severityNumber
+//     and severityText from Severities: those of the last entry whose Min
+//     is at most the value.
+//   - all other fields become attributes.
+//
+// The instrumentation scope is Scope if set, or otherwise the package of
+// the Config of the logger.
+type OTLPFmter struct {
+	// SeverityKey and Severities determine the severity.
+	SeverityKey string         `json:"severityKey,omitempty"`
+	Severities  []OTLPSeverity `json:"severities,omitempty"`
+	// TimeKey, BodyKey, TraceIDKey and SpanIDKey default to "time",
+	// "msg", "trace_id" and "span_id".
+	TimeKey    string `json:"timeKey,omitempty"`
+	BodyKey    string `json:"bodyKey,omitempty"`
+	TraceIDKey string `json:"traceIDKey,omitempty"`
+	SpanIDKey  string `json:"spanIDKey,omitempty"`
+	// Scope is the name of the instrumentation scope.
+	Scope string `json:"scope,omitempty"`
+	// Resource holds the attributes of the resource, such as
+	// "service.name".
+	Resource map[string]string `json:"resource,omitempty"`
+}
+
+func (f *OTLPFmter) Fmt(w io.Writer, d []byte) error {
+	return f.FmtConfig(nil, w, d)
+}
+
+func (f *OTLPFmter) FmtConfig(cfg *Config, w io.Writer, d []byte) error {
+	scope := f.Scope
+	if scope == "" && cfg != nil {
+		scope = cfg.Package()
+	}
+	st := schemaPool.Get().(*schemaState)
+	defer schemaPool.Put(st)
+	keys := st.names[:0]
+	for k := range f.Resource {
+		keys = append(keys, k)
+	}
+	// sorted by insertion, as sort.Strings allocates.
+	for i := 1; i < len(keys); i++ {
+		for j := i; j > 0 && keys[j] < keys[j-1]; j-- {
+			keys[j], keys[j-1] = keys[j-1], keys[j]
+		}
+	}
+	buf := append(st.buf[:0], `{"resourceLogs":[{"resource":{"attributes":[`...)
+	for i, k := range keys {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, `{"key":`...)
+		buf = appendString(buf, k, false)
+		buf = append(buf, `,"value":{"stringValue":`...)
+		buf = appendString(buf, f.Resource[k], false)
+		buf = append(buf, "}}"...)
+	}
+	for i := range keys {
+		keys[i] = ""
+	}
+	st.names = keys
+	buf = append(buf, `]},"scopeLogs":[{"scope":{"name":`...)
+	buf = appendString(buf, scope, false)
+	buf = append(buf, `},"logRecords":[`...)
+	st.buf = buf
+	f.record(st, d)
+	st.buf = append(st.buf, "]}]}]}\n"...)
+	_, err := w.Write(st.buf)
+	return err
+}
+
+// record appends the LogRecord for 'd' to st.buf.
+func (f *OTLPFmter) record(st *schemaState, d []byte) {
+	var (
+		timeKey  = orDefault(f.TimeKey, "time")
+		bodyKey  = orDefault(f.BodyKey, "msg")
+		traceKey = orDefault(f.TraceIDKey, "trace_id")
+		spanKey  = orDefault(f.SpanIDKey, "span_id")
+		now      = time.Now()
+		ts       time.Time
+		body     []byte
+		traceID  []byte
+		spanID   []byte
+		sev      *OTLPSeverity
+		attrs    = st.attrs[:0]
+	)
+	i := skipSpace(d, 0)
+	if i < len(d) && d[i] == '{' {
+		sc := &st.sc
+		sc.resetAt(d, i)
+		for m, ok := sc.field(); ok; m, ok = sc.field() {
+			k, v := d[m.ks:m.ke], d[m.vs:m.ve]
+			switch {
+			case keyIs(k, timeKey) && v[0] == '"':
+				st.tmp = AppendUnquote(st.tmp[:0], v)
+				if t, err := time.Parse(time.RFC3339Nano, string(st.tmp)); err == nil {
+					ts = t
+					continue
+				}
+			case keyIs(k, bodyKey):
+				body = v
+				continue
+			case keyIs(k, traceKey) && v[0] == '"':
+				if isHexID(AppendUnquote(st.tmp[:0], v), 32) {
+					traceID = v
+					continue
+				}
+			case keyIs(k, spanKey) && v[0] == '"':
+				if isHexID(AppendUnquote(st.tmp[:0], v), 16) {
+					spanID = v
+					continue
+				}
+			case keyIs(k, f.SeverityKey):
+				if n, err := strconv.Atoi(string(v)); err == nil {
+					for j := range f.Severities {
+						if f.Severities[j].Min <= n {
+							sev = &f.Severities[j]
+						}
+					}
+					continue
+				}
+			}
+			attrs = append(attrs, m)
+		}
+	} else {
+		body = d[i:]
+	}
+	st.attrs = attrs
+	buf := append(st.buf, '{')
+	if !ts.IsZero() {
+		buf = append(buf, `"timeUnixNano":"`...)
+		buf = strconv.AppendInt(buf, ts.UnixNano(), 10)
+		buf = append(buf, `",`...)
+	}
+	buf = append(buf, `"observedTimeUnixNano":"`...)
+	buf = strconv.AppendInt(buf, now.UnixNano(), 10)
+	buf = append(buf, '"')
+	if sev != nil {
+		buf = append(buf, `,"severityNumber":`...)
+		buf = strconv.AppendInt(buf, int64(sev.Number), 10)
+		if sev.Text != "" {
+			buf = append(buf, `,"severityText":`...)
+			buf = appendString(buf, sev.Text, false)
+		}
+	}
+	if body != nil {
+		buf = append(buf, `,"body":`...)
+		st.buf = buf
+		st.otlpValue(body)
+		buf = st.buf
+	}
+	if len(attrs) > 0 {
+		buf = append(buf, `,"attributes":[`...)
+		for j, m := range attrs {
+			if j > 0 {
+				buf = append(buf, ',')
+			}
+			st.buf = buf
+			st.otlpKeyValue(d[m.ks:m.ke], d[m.vs:m.ve])
+			buf = st.buf
+		}
+		buf = append(buf, ']')
+	}
+	for _, id := range [...]struct {
+		key string
+		v   []byte
+	}{{`,"traceId":`, traceID}, {`,"spanId":`, spanID}} {
+		if id.v != nil {
+			buf = append(buf, id.key...)
+			buf = append(buf, '"')
+			buf = AppendUnquote(buf, id.v)
+			buf = append(buf, '"')
+		}
+	}
+	st.buf = append(buf, '}')
+}
+
+func isHexID(s []byte, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// isInt64 returns whether the json number 'v' is an integer in the range
+// of an int64, without the allocation of the error of strconv.ParseInt for
+// other numbers.
+func isInt64(v []byte) bool {
+	digits := bytes.TrimPrefix(v, []byte{'-'})
+	if len(digits) == 0 {
+		return false
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	_, err := strconv.ParseInt(string(v), 10, 64)
+	return err == nil
+}
+
+// otlpKeyValue appends a KeyValue with the key of the json string 'k'
+// and the value of the json 'v' to st.buf.
+func (st *schemaState) otlpKeyValue(k, v []byte) {
+	st.buf = append(st.buf, `{"key":`...)
+	st.buf = st.appendTextString(st.buf, k)
+	st.buf = append(st.buf, `,"value":`...)
+	st.otlpValue(v)
+	st.buf = append(st.buf, '}')
+}
+
+// otlpValue appends the AnyValue for the json 'v' to st.buf.
+func (st *schemaState) otlpValue(v []byte) {
+	switch v[0] {
+	case '"':
+		st.buf = append(st.buf, `{"stringValue":`...)
+		st.buf = st.appendTextString(st.buf, v)
+	case 't':
+		st.buf = append(st.buf, `{"boolValue":true`...)
+	case 'f':
+		st.buf = append(st.buf, `{"boolValue":false`...)
+	case 'n':
+		st.buf = append(st.buf, '{')
+	case '{':
+		st.buf = append(st.buf, `{"kvlistValue":{"values":[`...)
+		var sc Scanner
+		sc.resetAt(v, 0)
+		sep := false
+		for m, ok := sc.field(); ok; m, ok = sc.field() {
+			if sep {
+				st.buf = append(st.buf, ',')
+			}
+			sep = true
+			st.otlpKeyValue(v[m.ks:m.ke], v[m.vs:m.ve])
+		}
+		st.buf = append(st.buf, "]}"...)
+	case '[':
+		st.buf = append(st.buf, `{"arrayValue":{"values":[`...)
+		var sc Scanner
+		sc.resetAt(v, 0)
+		sep := false
+		for m, ok := sc.elem(); ok; m, ok = sc.elem() {
+			if sep {
+				st.buf = append(st.buf, ',')
+			}
+			sep = true
+			st.otlpValue(v[m.vs:m.ve])
+		}
+		st.buf = append(st.buf, "]}"...)
+	default:
+		if !isInt64(v) {
+			// fractions, exponents and integers out of the range of
+			// intValue.
+			st.buf = append(st.buf, `{"doubleValue":`...)
+			st.buf = append(st.buf, v...)
+		} else {
+			// 64 bit integers are strings in OTLP/JSON.
+			st.buf = append(st.buf, `{"intValue":"`...)
+			st.buf = append(st.buf, v...)
+			st.buf = append(st.buf, '"')
+		}
+	}
+	st.buf = append(st.buf, '}')
+}
+
+// OTLPWriter is an io.WriteCloser which sends the lines written by an
+// OTLPFmter in batches to an OTLP/HTTP endpoint of a collector, such as
+// "http://localhost:4318/v1/logs".  LogRecords are grouped by
+// instrumentation scope, and the resource is taken from the first line of
+// each batch.
+//
+// A batch is completed when it holds MaxBatch records, every Interval,
+// and on Flush and Close.  Completed batches are sent from a separate
+// goroutine, so that writes do not wait for the collector.  If MaxQueue
+// batches are waiting to be sent, the oldest is dropped.  Errors in
+// sending and drops are returned by the next call to Write, Flush or
+// Close.
+//
+// An OTLPWriter is safe for concurrent use.
+type OTLPWriter struct {
+	// Client is the client for requests, http.DefaultClient if nil.
+	Client *http.Client
+	// Header holds additional headers for requests.
+	Header http.Header
+	// MaxQueue is the number of batches waiting to be sent, 16 if
+	// zero.
+	MaxQueue int
+
+	url      string
+	maxBatch int
+	mu       sync.Mutex
+	cond     *sync.Cond
+	resource []byte
+	scopes   []otlpScope
+	n        int
+	queue    [][]byte
+	sending  bool
+	closed   bool
+	err      error
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+type otlpScope struct {
+	scope []byte
+	recs  [][]byte
+}
+
+// NewOTLPWriter creates an OTLPWriter for 'url' sending batches of at
+// most 'maxBatch' records, and, if 'interval' is positive, sending
+// pending records every 'interval'.
+func NewOTLPWriter(url string, maxBatch int, interval time.Duration) *OTLPWriter {
+	if maxBatch <= 0 {
+		maxBatch = 1
+	}
+	w := &OTLPWriter{
+		url:      url,
+		maxBatch: maxBatch,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	w.cond = sync.NewCond(&w.mu)
+	go w.loop()
+	if interval > 0 {
+		go w.tick(interval)
+	}
+	return w
+}
+
+// loop sends the queued batches until 'w' is closed and the queue is
+// empty.
+func (w *OTLPWriter) loop() {
+	defer close(w.done)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for {
+		for len(w.queue) == 0 && !w.closed {
+			w.cond.Wait()
+		}
+		if len(w.queue) == 0 {
+			return
+		}
+		body := w.queue[0]
+		w.queue[0] = nil
+		w.queue = w.queue[1:]
+		w.sending = true
+		w.mu.Unlock()
+		err := w.post(body)
+		w.mu.Lock()
+		w.sending = false
+		w.setErr(err)
+		w.cond.Broadcast()
+	}
+}
+
+func (w *OTLPWriter) tick(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-t.C:
+			w.mu.Lock()
+			w.cut()
+			w.mu.Unlock()
+		}
+	}
+}
+
+func (w *OTLPWriter) setErr(err error) {
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+}
+
+// Write adds the LogRecords of the OTLP/JSON lines in 'p' to the batch.
+func (w *OTLPWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, ErrClosed
+	}
+	for _, ln := range bytes.Split(p, []byte{'\n'}) {
+		if len(bytes.TrimSpace(ln)) == 0 {
+			continue
+		}
+		if err := w.add(ln); err != nil {
+			return 0, err
+		}
+	}
+	if w.n >= w.maxBatch {
+		w.cut()
+	}
+	if err := w.err; err != nil {
+		w.err = nil
+		return len(p), err
+	}
+	return len(p), nil
+}
+
+func (w *OTLPWriter) add(ln []byte) error {
+	rls, ok := lookup(ln, "resourceLogs")
+	if !ok {
+		return errors.New("not an OTLP/JSON logs request")
+	}
+	rangeElems(rls, 0, func(rl member) bool {
+		rd := rls[rl.vs:rl.ve]
+		if w.resource == nil {
+			if res, ok := getField(rd, "resource"); ok {
+				w.resource = append([]byte(nil), res...)
+			}
+		}
+		sls, _ := getField(rd, "scopeLogs")
+		rangeElems(sls, 0, func(sl member) bool {
+			sd := sls[sl.vs:sl.ve]
+			scope, _ := getField(sd, "scope")
+			g := w.group(scope)
+			recs, _ := getField(sd, "logRecords")
+			rangeElems(recs, 0, func(r member) bool {
+				g.recs = append(g.recs, append([]byte(nil), recs[r.vs:r.ve]...))
+				w.n++
+				return true
+			})
+			return true
+		})
+		return true
+	})
+	return nil
+}
+
+func (w *OTLPWriter) group(scope []byte) *otlpScope {
+	for i := range w.scopes {
+		if bytes.Equal(w.scopes[i].scope, scope) {
+			return &w.scopes[i]
+		}
+	}
+	w.scopes = append(w.scopes, otlpScope{scope: append([]byte(nil), scope...)})
+	return &w.scopes[len(w.scopes)-1]
+}
+
+// cut completes the batch and queues it for sending.
+func (w *OTLPWriter) cut() {
+	if w.n == 0 {
+		return
+	}
+	var b bytes.Buffer
+	b.WriteString(`{"resourceLogs":[{`)
+	if w.resource != nil {
+		b.WriteString(`"resource":`)
+		b.Write(w.resource)
+		b.WriteByte(',')
+	}
+	b.WriteString(`"scopeLogs":[`)
+	for i, g := range w.scopes {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('{')
+		if len(g.scope) > 0 {
+			b.WriteString(`"scope":`)
+			b.Write(g.scope)
+			b.WriteByte(',')
+		}
+		b.WriteString(`"logRecords":[`)
+		b.Write(bytes.Join(g.recs, []byte{','}))
+		b.WriteString(`]}`)
+	}
+	b.WriteString(`]}]}`)
+	w.resource, w.scopes, w.n = nil, nil, 0
+	size := w.MaxQueue
+	if size <= 0 {
+		size = 16
+	}
+	if len(w.queue) >= size {
+		w.queue[0] = nil
+		w.queue = w.queue[1:]
+		w.setErr(fmt.Errorf("otlp: %s: queue full, batch dropped", w.url))
+	}
+	w.queue = append(w.queue, b.Bytes())
+	w.cond.Broadcast()
+}
+
+func (w *OTLPWriter) post(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, vs := range w.Header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c := w.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("otlp: %s: %s", w.url, resp.Status)
+	}
+	return nil
+}
+
+// Flush sends any pending records and waits until the queued batches
+// are sent, returning any error since the last call to Write or Flush.
+func (w *OTLPWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrClosed
+	}
+	w.cut()
+	for len(w.queue) > 0 || w.sending {
+		w.cond.Wait()
+	}
+	err := w.err
+	w.err = nil
+	return err
+}
+
+// Close sends any pending records, waits until the queued batches are
+// sent and stops 'w'.
+func (w *OTLPWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return ErrClosed
+	}
+	w.closed = true
+	w.cut()
+	w.cond.Broadcast()
+	w.mu.Unlock()
+	close(w.stop)
+	<-w.done
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.err
+	w.err = nil
+	return err
+}
@@ -0,0 +1,190 @@
+package L
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOTLPFmter(t *testing.T) {
+	w := bytes.NewBuffer(nil)
+	cfg := NewConfig()
+	cfg.W = w
+	cfg.F = &OTLPFmter{
+		SeverityKey: "lvl",
+		Severities:  []OTLPSeverity{{Min: 0, Number: 9, Text: "INFO"}, {Min: 2, Number: 17, Text: "ERROR"}},
+		Resource:    map[string]string{"service.name": "svc"},
+	}
+	l := New(cfg)
+	l.Dict().
+		Field("time", "2022-01-02T03:04:05.5Z").
+		Field("msg", "hi").
+		Field("lvl", 2).
+		Field("trace_id", "0102030405060708090a0b0c0d0e0f10").
+		Field("span_id", "0102030405060708").
+		Field("span_id", "zz").
+		Field("n", 12345678901).
+		Field("x", 1.5).
+		Field("u", uint64(math.MaxUint64)).
+		Field("d", map[string]any{"a": []any{true, nil}}).
+		Log()
+	var req struct {
+		ResourceLogs []struct {
+			Resource struct {
+				Attributes []otlpKV `json:"attributes"`
+			} `json:"resource"`
+			ScopeLogs []struct {
+				Scope struct {
+					Name string `json:"name"`
+				} `json:"scope"`
+				LogRecords []struct {
+					TimeUnixNano   string   `json:"timeUnixNano"`
+					Observed       string   `json:"observedTimeUnixNano"`
+					SeverityNumber int      `json:"severityNumber"`
+					SeverityText   string   `json:"severityText"`
+					Body           otlpAny  `json:"body"`
+					Attributes     []otlpKV `json:"attributes"`
+					TraceID        string   `json:"traceId"`
+					SpanID         string   `json:"spanId"`
+				} `json:"logRecords"`
+			} `json:"scopeLogs"`
+		} `json:"resourceLogs"`
+	}
+	if err := json.Unmarshal(w.Bytes(), &req); err != nil {
+		t.Fatalf("%s: %v", w.String(), err)
+	}
+	rl := req.ResourceLogs[0]
+	if got := rl.Resource.Attributes; len(got) != 1 || got[0].Key != "service.name" || *got[0].Value.StringValue != "svc" {
+		t.Errorf("resource: %v", got)
+	}
+	if got := rl.ScopeLogs[0].Scope.Name; got != "github.com/scott-cotton/L" {
+		t.Errorf("scope: %s", got)
+	}
+	r := rl.ScopeLogs[0].LogRecords[0]
+	if r.TimeUnixNano != "1641092645500000000" || r.Observed == "" {
+		t.Errorf("time: %s %s", r.TimeUnixNano, r.Observed)
+	}
+	if r.SeverityNumber != 17 || r.SeverityText != "ERROR" {
+		t.Errorf("severity: %d %s", r.SeverityNumber, r.SeverityText)
+	}
+	if r.Body.StringValue == nil || *r.Body.StringValue != "hi" {
+		t.Errorf("body: %v", r.Body)
+	}
+	if r.TraceID != "0102030405060708090a0b0c0d0e0f10" || r.SpanID != "0102030405060708" {
+		t.Errorf("ids: %s %s", r.TraceID, r.SpanID)
+	}
+	attrs, _ := json.Marshal(r.Attributes)
+	want := `[{"key":"span_id","value":{"stringValue":"zz"}},` +
+		`{"key":"n","value":{"intValue":"12345678901"}},` +
+		`{"key":"x","value":{"doubleValue":1.5}},` +
+		`{"key":"u","value":{"doubleValue":18446744073709552000}},` +
+		`{"key":"d","value":{"kvlistValue":{"values":[{"key":"a","value":{"arrayValue":{"values":[{"boolValue":true},{}]}}}]}}}]`
+	if string(attrs) != want {
+		t.Errorf("attributes:\ngot  %s\nwant %s", attrs, want)
+	}
+}
+
+type otlpKV struct {
+	Key   string  `json:"key"`
+	Value otlpAny `json:"value"`
+}
+
+type otlpAny struct {
+	StringValue *string          `json:"stringValue,omitempty"`
+	BoolValue   *bool            `json:"boolValue,omitempty"`
+	IntValue    *string          `json:"intValue,omitempty"`
+	DoubleValue *float64         `json:"doubleValue,omitempty"`
+	KvlistValue *json.RawMessage `json:"kvlistValue,omitempty"`
+	ArrayValue  *json.RawMessage `json:"arrayValue,omitempty"`
+}
+
+func TestOTLPWriter(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		bodies []string
+		status = http.StatusOK
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path != "/v1/logs" || r.Header.Get("Content-Type") != "application/json" || !json.Valid(b) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		bodies = append(bodies, string(b))
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	ow := NewOTLPWriter(srv.URL+"/v1/logs", 3, 0)
+	f := &OTLPFmter{Resource: map[string]string{"service.name": "svc"}}
+	for i, scope := range []string{"a", "b", "a", "c"} {
+		f.Scope = scope
+		if err := f.Fmt(ow, []byte(`{"i":`+string(rune('0'+i))+`}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ow.Close(); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	if len(bodies) != 2 {
+		t.Fatalf("got %d requests", len(bodies))
+	}
+	var req struct {
+		ResourceLogs []struct {
+			ScopeLogs []struct {
+				Scope      struct{ Name string }
+				LogRecords []json.RawMessage
+			}
+		}
+	}
+	json.Unmarshal([]byte(bodies[0]), &req)
+	sls := req.ResourceLogs[0].ScopeLogs
+	if len(sls) != 2 || sls[0].Scope.Name != "a" || len(sls[0].LogRecords) != 2 || sls[1].Scope.Name != "b" {
+		t.Errorf("batch: %s", bodies[0])
+	}
+	status = http.StatusServiceUnavailable
+	mu.Unlock()
+
+	// periodic sending and errors
+	ow = NewOTLPWriter(srv.URL+"/v1/logs", 100, 10*time.Millisecond)
+	f.Fmt(ow, []byte(`{"a":1}`))
+	time.Sleep(100 * time.Millisecond)
+	if err := ow.Close(); err == nil {
+		t.Error("expected error")
+	}
+	if _, err := ow.Write([]byte("{}\n")); err != ErrClosed {
+		t.Errorf("write after close: %v", err)
+	}
+
+	// writes do not wait for the collector, and drop the oldest batch
+	// when the queue is full.
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	ow = NewOTLPWriter(slow.URL, 1, 0)
+	ow.MaxQueue = 1
+	var dropped error
+	for i := 0; i < 3 && dropped == nil; i++ {
+		dropped = f.Fmt(ow, []byte(`{"a":1}`))
+	}
+	if dropped == nil {
+		t.Error("expected a dropped batch")
+	}
+	if _, err := ow.Write([]byte("{}\n")); err == nil || err == ErrClosed {
+		t.Errorf("invalid line: %v", err)
+	}
+	close(release)
+	if err := ow.Close(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package L_test
+
+// raceEnabled is whether the tests run with the race detector, under
+// which sync.Pool drops some of the values put in it.
+const raceEnabled = true
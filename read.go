@@ -0,0 +1,382 @@
+package L
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Get returns the raw json of the value of the first field with key 'key'
+// in 't', which should be a dict.
+func (t *Obj) Get(key string) ([]byte, bool) {
+	if t == nil {
+		return nil, false
+	}
+	return getField(t.D()[t.i:], key)
+}
+
+// Lookup returns the raw json of the value at 'path' in 't'.  'path'
+// is a sequence of dict keys and array indices separated by '.', such
+// as "a.b.0.c".  The empty path refers to 't' itself.
+func (t *Obj) Lookup(path string) ([]byte, bool) {
+	if t == nil {
+		return nil, false
+	}
+	return lookup(t.D()[t.i:], path)
+}
+
+// Range calls 'fn' with the key and raw json value of each field of 't'
+// in order, until 'fn' returns false.  If 't' is an array, the keys are
+// the indices of its elements.
+func (t *Obj) Range(fn func(key string, raw []byte) bool) {
+	if t == nil {
+		return
+	}
+	d := t.D()[t.i:]
+	i := skipSpace(d, 0)
+	if i == len(d) {
+		return
+	}
+	switch d[i] {
+	case '{':
+		rangeFields(d, i, func(m member) bool {
+			return fn(unquote(d[m.ks:m.ke]), d[m.vs:m.ve])
+		})
+	case '[':
+		n := 0
+		rangeElems(d, i, func(m member) bool {
+			n++
+			return fn(strconv.Itoa(n-1), d[m.vs:m.ve])
+		})
+	}
+}
+
+// Delete removes all fields with key 'key' from 't', which should be a
+// dict, returning whether any were removed.  Delete does nothing if 't'
+// has an open child.
+func (t *Obj) Delete(key string) bool {
+	if !t.editable() {
+		return false
+	}
+	res := false
+	for {
+		d := t.D()
+		var (
+			ms []member
+			k  = -1
+		)
+		rangeFields(d, t.i, func(m member) bool {
+			if k == -1 && keyIs(d[m.ks:m.ke], key) {
+				k = len(ms)
+			}
+			ms = append(ms, m)
+			return true
+		})
+		if k == -1 {
+			break
+		}
+		res = true
+		switch {
+		case k > 0:
+			t.replaceBytes(ms[k-1].ve, ms[k].ve, nil)
+		case len(ms) > 1:
+			t.replaceBytes(ms[0].ks, ms[1].ks, nil)
+		default:
+			t.replaceBytes(ms[0].ks, ms[0].ve, nil)
+			if t.kind == '{' && !t.closed {
+				// 't' is the open dict being validated, now empty.
+				t.hadChild = false
+				t.getRoot().v.state = stKeyOrEnd
+			}
+		}
+	}
+	return res
+}
+
+// Replace replaces the values of all fields with key 'key' in 't', which
+// should be a dict, with 'v', encoded as with Any.  Replace returns whether
+// any field was replaced.  Replace does nothing if 't' has an open child.
+func (t *Obj) Replace(key string, v any) bool {
+	if !t.editable() {
+		return false
+	}
+	s := t.scratch()
+	s.Any(v)
+	if err := s.validate(true); err != nil {
+		t.fail(err)
+		return false
+	}
+	var vs []member
+	d := t.D()
+	rangeFields(d, t.i, func(m member) bool {
+		if keyIs(d[m.ks:m.ke], key) {
+			vs = append(vs, m)
+		}
+		return true
+	})
+	for i := len(vs) - 1; i >= 0; i-- {
+		t.replaceBytes(vs[i].vs, vs[i].ve, s.d)
+	}
+	return len(vs) > 0
+}
+
+// editable returns whether the buffer of 't' may be modified in place,
+// bringing validation up to date.
+func (t *Obj) editable() bool {
+	if t == nil || t.child != nil {
+		return false
+	}
+	return t.validate(false) == nil
+}
+
+// scratch returns a new root Obj with the settings of 't'.
+func (t *Obj) scratch() *Obj {
+	r := t.getRoot()
+	return &Obj{opts: r.opts}
+}
+
+// replaceBytes replaces the bytes from 'a' to 'b' in the buffer of 't'
+// with 'repl', which must not change the validation state.
+func (t *Obj) replaceBytes(a, b int, repl []byte) {
+	r := t.getRoot()
+	d := r.d
+	delta := len(repl) - (b - a)
+	if delta > 0 {
+		d = append(d, repl[:delta]...)
+	}
+	copy(d[a+len(repl):], d[b:])
+	copy(d[a:], repl)
+	if delta < 0 {
+		d = d[:len(d)+delta]
+	}
+	r.d = d
+	// the validator state at 'b' is the same as at the end of 'repl',
+	// unless an insertion is yet to be scanned.
+	if r.v.off > b || r.v.off == b && a < b {
+		r.v.off += delta
+	}
+	t.moveLazy(a, b, len(repl))
+}
+
+// member gives the offsets of a field or element of a
+// dict or array.  For elements, ks and ke are zero.
+type member struct {
+	ks, ke int // the key, including its quotes
+	vs, ve int // the value
+}
+
+// rangeFields calls 'fn' with each field of the dict starting at
+// d[i], until fn returns false.  The dict need not be closed.
+func rangeFields(d []byte, i int, fn func(member) bool) {
+	i++
+	for {
+		i = skipSpace(d, i)
+		if i >= len(d) {
+			return
+		}
+		switch d[i] {
+		case ',':
+			i++
+			continue
+		case '"':
+		default:
+			return
+		}
+		var m member
+		m.ks = i
+		m.ke = skipString(d, i)
+		j := skipSpace(d, m.ke)
+		if j >= len(d) || d[j] != ':' {
+			return
+		}
+		m.vs = skipSpace(d, j+1)
+		m.ve = skipValue(d, m.vs)
+		if m.vs == m.ve || !fn(m) {
+			return
+		}
+		i = m.ve
+	}
+}
+
+// rangeElems calls 'fn' with each element of the array starting at
+// d[i], until fn returns false.  The array need not be closed.
+func rangeElems(d []byte, i int, fn func(member) bool) {
+	i++
+	for {
+		i = skipSpace(d, i)
+		if i >= len(d) || d[i] == ']' {
+			return
+		}
+		if d[i] == ',' {
+			i++
+			continue
+		}
+		var m member
+		m.vs = i
+		m.ve = skipValue(d, i)
+		if m.vs == m.ve || !fn(m) {
+			return
+		}
+		i = m.ve
+	}
+}
+
+// getField returns the value of the first field with key 'key' in the
+// dict 'd'.
+func getField(d []byte, key string) (res []byte, ok bool) {
+	i := skipSpace(d, 0)
+	if i == len(d) || d[i] != '{' {
+		return nil, false
+	}
+	rangeFields(d, i, func(m member) bool {
+		if keyIs(d[m.ks:m.ke], key) {
+			res, ok = d[m.vs:m.ve], true
+			return false
+		}
+		return true
+	})
+	return
+}
+
+// getElem returns the element of the array 'd' at index 'n'.
+func getElem(d []byte, n int) (res []byte, ok bool) {
+	i := skipSpace(d, 0)
+	if i == len(d) || d[i] != '[' {
+		return nil, false
+	}
+	rangeElems(d, i, func(m member) bool {
+		if n == 0 {
+			res, ok = d[m.vs:m.ve], true
+			return false
+		}
+		n--
+		return true
+	})
+	return
+}
+
+// lookup returns the value at the dotted 'path' in 'd'.
+func lookup(d []byte, path string) ([]byte, bool) {
+	if path == "" {
+		i := skipSpace(d, 0)
+		return d[i:skipValue(d, i)], i < len(d)
+	}
+	for {
+		var (
+			elt  string
+			more bool
+			ok   bool
+		)
+		elt, path, more = strings.Cut(path, ".")
+		i := skipSpace(d, 0)
+		if i == len(d) {
+			return nil, false
+		}
+		switch d[i] {
+		case '{':
+			d, ok = getField(d, elt)
+		case '[':
+			var n int
+			n, ok = atoi(elt)
+			if ok {
+				d, ok = getElem(d, n)
+			}
+		}
+		if !ok {
+			return nil, false
+		}
+		if !more {
+			return d, true
+		}
+	}
+}
+
+// atoi parses a non-negative decimal integer.
+func atoi(s string) (int, bool) {
+	if s == "" {
+		return 0, false
+	}
+	n := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n, true
+}
+
+// keyIs returns whether the json string 'k' encodes 'key'.
+func keyIs(k []byte, key string) bool {
+	if len(k) == len(key)+2 && string(k[1:len(k)-1]) == key {
+		return true
+	}
+	for _, c := range k {
+		if c == '\\' {
+			return unquote(k) == key
+		}
+	}
+	return false
+}
+
+func skipSpace(d []byte, i int) int {
+	for i < len(d) && isSpace(d[i]) {
+		i++
+	}
+	return i
+}
+
+// skipString returns the offset following the json string starting
+// at d[i].
+func skipString(d []byte, i int) int {
+	i++
+	for i < len(d) {
+		switch d[i] {
+		case '\\':
+			i += 2
+			continue
+		case '"':
+			return i + 1
+		}
+		i++
+	}
+	return len(d)
+}
+
+// skipValue returns the offset following the json value starting
+// at d[i], or len(d) if the value is not terminated.
+func skipValue(d []byte, i int) int {
+	if i >= len(d) {
+		return len(d)
+	}
+	switch d[i] {
+	case '"':
+		return skipString(d, i)
+	case '{', '[':
+		depth := 0
+		for i < len(d) {
+			switch d[i] {
+			case '"':
+				i = skipString(d, i)
+				continue
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return i + 1
+				}
+			}
+			i++
+		}
+		return len(d)
+	}
+	for i < len(d) {
+		switch d[i] {
+		case ',', '}', ']', ' ', '\t', '\n', '\r':
+			return i
+		}
+		i++
+	}
+	return i
+}
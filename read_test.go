@@ -0,0 +1,100 @@
+package L
+
+import (
+	"testing"
+)
+
+func TestGetLookup(t *testing.T) {
+	o := (&Obj{}).Dict().
+		Field("a", map[string]any{"b": []any{1, map[string]int{"c": 3}}}).
+		Field("s", "x\"y").
+		Field("e\\k", true)
+	for _, tc := range []struct {
+		path string
+		want string
+	}{
+		{"a.b.0", `1`},
+		{"a.b.1.c", `3`},
+		{"a.b.1", `{"c":3}`},
+		{"s", `"x\"y"`},
+		{"e\\k", `true`},
+		{"a.b.2", ``},
+		{"a.x", ``},
+		{"s.0", ``},
+	} {
+		got, ok := o.Lookup(tc.path)
+		if ok != (tc.want != "") || string(got) != tc.want {
+			t.Errorf("%s: got %q %t want %q", tc.path, got, ok, tc.want)
+		}
+	}
+	if v, ok := o.Get("s"); !ok || string(v) != `"x\"y"` {
+		t.Errorf("get: got %q %t", v, ok)
+	}
+	if _, ok := o.Get("b"); ok {
+		t.Errorf("get b: expected absent")
+	}
+}
+
+func TestRange(t *testing.T) {
+	o := (&Obj{}).Dict().Field("a", 1).Field("b", []int{1, 2})
+	var keys, vals []string
+	o.Range(func(k string, raw []byte) bool {
+		keys = append(keys, k)
+		vals = append(vals, string(raw))
+		return true
+	})
+	if len(keys) != 2 || keys[0] != "a" || keys[1] != "b" || vals[1] != "[1,2]" {
+		t.Errorf("got %v %v", keys, vals)
+	}
+}
+
+func TestDeleteReplace(t *testing.T) {
+	for _, tc := range []struct {
+		del  string
+		want string
+	}{
+		{"a", `{"b":2,"c":3}`},
+		{"b", `{"a":1,"c":3}`},
+		{"c", `{"a":1,"b":2}`},
+		{"x", `{"a":1,"b":2,"c":3}`},
+	} {
+		o := (&Obj{}).Dict().Field("a", 1).Field("b", 2).Field("c", 3)
+		o.Delete(tc.del)
+		if err := o.Close(); err != nil {
+			t.Errorf("delete %s: %v", tc.del, err)
+			continue
+		}
+		if got := string(o.D()); got != tc.want {
+			t.Errorf("delete %s: got %s want %s", tc.del, got, tc.want)
+		}
+	}
+
+	o := (&Obj{}).Dict().Field("a", 1)
+	o.Delete("a")
+	o.Field("k", "v").Field("k", "w").Field("pw", "secret")
+	if !o.Replace("pw", Redacted) {
+		t.Errorf("replace failed")
+	}
+	o.Delete("k")
+	o.Field("z", 0)
+	if err := o.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"pw":"[redacted]","z":0}`
+	if got := string(o.D()); got != want {
+		t.Errorf("got %s want %s", got, want)
+	}
+
+	// the only field of a closed dict, deleted through its root.
+	r := &Obj{}
+	r.Dict().Field("a", 1).Close()
+	if !r.Delete("a") {
+		t.Errorf("delete failed")
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(r.D()); got != "{}" {
+		t.Errorf("got %s want {}", got)
+	}
+}
@@ -0,0 +1,489 @@
+package L
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Redacted is the value written in place of struct fields
+// tagged with 'redact'.
+const Redacted = "[redacted]"
+
+// maxDepth bounds the number of pointers, interfaces, maps and slices
+// which are followed when encoding a value, so that cyclic values
+// terminate.
+const maxDepth = 1000
+
+// encState is the state of the reflection based encoding of a single value.
+type encState struct {
+	o     *Obj
+	depth int
+}
+
+// encFunc encodes 'v' into 'e.o' following the conventions of
+// the Obj value methods: if the Obj has a child, a ',' is written
+// first, and afterwards the Obj has a child.
+type encFunc func(e *encState, v reflect.Value)
+
+var encCache sync.Map // reflect.Type -> encFunc
+
+// reflect encodes 'v' by reflection, as documented in Any.
+func (t *Obj) reflect(v any) *Obj {
+	rv := reflect.ValueOf(v)
+	e := &encState{o: t}
+	typeEncoder(rv.Type())(e, rv)
+	return t
+}
+
+func typeEncoder(rt reflect.Type) encFunc {
+	if f, ok := encCache.Load(rt); ok {
+		return f.(encFunc)
+	}
+	// recursive types refer to themselves through
+	// a placeholder until they are built.
+	var (
+		wg sync.WaitGroup
+		f  encFunc
+	)
+	wg.Add(1)
+	g, loaded := encCache.LoadOrStore(rt, encFunc(func(e *encState, v reflect.Value) {
+		wg.Wait()
+		f(e, v)
+	}))
+	if loaded {
+		return g.(encFunc)
+	}
+	f = newTypeEncoder(rt)
+	wg.Done()
+	encCache.Store(rt, f)
+	return f
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	errorType         = reflect.TypeOf((*error)(nil)).Elem()
+	stringerType      = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	objType           = reflect.TypeOf((*Obj)(nil))
+	timeType          = reflect.TypeOf(time.Time{})
+	durationType      = reflect.TypeOf(time.Duration(0))
+)
+
+func isSpecial(rt reflect.Type) bool {
+	switch rt {
+	case objType, timeType, durationType:
+		return true
+	}
+	return rt.Implements(jsonMarshalerType) ||
+		rt.Implements(textMarshalerType) ||
+		rt.Implements(errorType) ||
+		rt.Implements(stringerType)
+}
+
+func newTypeEncoder(rt reflect.Type) encFunc {
+	if isSpecial(rt) {
+		base := newKindEncoder(rt)
+		return func(e *encState, v reflect.Value) {
+			if !v.CanInterface() {
+				base(e, v)
+				return
+			}
+			e.o.Any(v.Interface())
+		}
+	}
+	return newKindEncoder(rt)
+}
+
+func newKindEncoder(rt reflect.Type) encFunc {
+	switch rt.Kind() {
+	case reflect.Bool:
+		return func(e *encState, v reflect.Value) { e.o.Bool(v.Bool()) }
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(e *encState, v reflect.Value) { e.o.Int64(v.Int()) }
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return func(e *encState, v reflect.Value) { e.o.Uint64(v.Uint()) }
+	case reflect.Float32:
+		return func(e *encState, v reflect.Value) { e.o.float(v.Float(), 32) }
+	case reflect.Float64:
+		return func(e *encState, v reflect.Value) { e.o.float(v.Float(), 64) }
+	case reflect.String:
+		return func(e *encState, v reflect.Value) { e.o.Str(v.String()) }
+	case reflect.Interface:
+		return encInterface
+	case reflect.Pointer:
+		return newPtrEncoder(rt)
+	case reflect.Struct:
+		return newStructEncoder(rt)
+	case reflect.Map:
+		return newMapEncoder(rt)
+	case reflect.Slice:
+		if rt.Elem().Kind() == reflect.Uint8 && !isSpecial(rt.Elem()) {
+			return func(e *encState, v reflect.Value) {
+				if v.IsNil() {
+					e.o.Null()
+					return
+				}
+				e.o.Bytes(v.Bytes())
+			}
+		}
+		return newArrayEncoder(rt)
+	case reflect.Array:
+		return newArrayEncoder(rt)
+	default:
+		return encFmt
+	}
+}
+
+func encFmt(e *encState, v reflect.Value) {
+	e.o.Str(fmt.Sprintf("%v", v))
+}
+
+func encInterface(e *encState, v reflect.Value) {
+	if v.IsNil() {
+		e.o.Null()
+		return
+	}
+	if !e.push() {
+		return
+	}
+	v = v.Elem()
+	typeEncoder(v.Type())(e, v)
+	e.depth--
+}
+
+// push increases the depth of e, writing a placeholder and
+// returning false if the maximum depth is exceeded.
+func (e *encState) push() bool {
+	if e.depth >= maxDepth {
+		e.o.Str("<max depth exceeded>")
+		return false
+	}
+	e.depth++
+	return true
+}
+
+func newPtrEncoder(rt reflect.Type) encFunc {
+	elem := typeEncoder(rt.Elem())
+	return func(e *encState, v reflect.Value) {
+		if v.IsNil() {
+			e.o.Null()
+			return
+		}
+		if !e.push() {
+			return
+		}
+		elem(e, v.Elem())
+		e.depth--
+	}
+}
+
+func newArrayEncoder(rt reflect.Type) encFunc {
+	elem := typeEncoder(rt.Elem())
+	isSlice := rt.Kind() == reflect.Slice
+	return func(e *encState, v reflect.Value) {
+		if isSlice && v.IsNil() {
+			e.o.Null()
+			return
+		}
+		if !e.push() {
+			return
+		}
+		o := e.o
+		if o.hadChild {
+			o.WriteByte(',')
+		}
+		o.WriteByte('[')
+		o.hadChild = false
+		n := v.Len()
+		for i := 0; i < n; i++ {
+			elem(e, v.Index(i))
+		}
+		o.WriteByte(']')
+		o.hadChild = true
+		e.depth--
+	}
+}
+
+type mapEntry struct {
+	key string
+	v   reflect.Value
+}
+
+func newMapEncoder(rt reflect.Type) encFunc {
+	elem := typeEncoder(rt.Elem())
+	return func(e *encState, v reflect.Value) {
+		if v.IsNil() {
+			e.o.Null()
+			return
+		}
+		if !e.push() {
+			return
+		}
+		o := e.o
+		if o.hadChild {
+			o.WriteByte(',')
+		}
+		o.WriteByte('{')
+		o.hadChild = false
+		encMapFields(e, v, elem)
+		o.WriteByte('}')
+		o.hadChild = true
+		e.depth--
+	}
+}
+
+// encMapFields writes the entries of the map 'v' as fields of e.o,
+// sorted by key.
+func encMapFields(e *encState, v reflect.Value, elem encFunc) {
+	entries := make([]mapEntry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		entries = append(entries, mapEntry{key: mapKey(iter.Key()), v: iter.Value()})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+	o := e.o
+	for i := range entries {
+		o.Str(entries[i].key).WriteByte(':')
+		o.hadChild = false
+		elem(e, entries[i].v)
+	}
+}
+
+func mapKey(k reflect.Value) string {
+	if k.Kind() == reflect.String {
+		return k.String()
+	}
+	if k.CanInterface() {
+		if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+			if k.Kind() == reflect.Pointer && k.IsNil() {
+				return ""
+			}
+			b, err := tm.MarshalText()
+			if err != nil {
+				return err.Error()
+			}
+			return string(b)
+		}
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10)
+	}
+	return fmt.Sprintf("%v", k)
+}
+
+// structField is an encoded field of a struct.
+type structField struct {
+	name      string
+	key       []byte // the encoded name followed by ':'
+	index     []int
+	omitEmpty bool
+	redact    bool
+	inlineMap bool
+	enc       encFunc
+}
+
+func newStructEncoder(rt reflect.Type) encFunc {
+	fields := structFields(rt)
+	return func(e *encState, v reflect.Value) {
+		o := e.o
+		if o.hadChild {
+			o.WriteByte(',')
+		}
+		o.WriteByte('{')
+		o.hadChild = false
+		encStructFields(e, v, fields)
+		o.WriteByte('}')
+		o.hadChild = true
+	}
+}
+
+func encStructFields(e *encState, v reflect.Value, fields []structField) {
+	o := e.o
+	for i := range fields {
+		f := &fields[i]
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok {
+			continue
+		}
+		if f.omitEmpty && isEmpty(fv) {
+			continue
+		}
+		if f.inlineMap {
+			if fv.Kind() == reflect.Pointer {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if !fv.IsNil() {
+				encMapFields(e, fv, typeEncoder(fv.Type().Elem()))
+			}
+			continue
+		}
+		if o.hadChild {
+			o.WriteByte(',')
+		}
+		o.Write(f.key)
+		o.hadChild = false
+		if f.redact {
+			o.Str(Redacted)
+		} else {
+			f.enc(e, fv)
+		}
+		o.hadChild = true
+	}
+}
+
+// fieldByIndex is like v.FieldByIndex, but returns false
+// rather than panicking on nil embedded pointers.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
+
+type fieldTag struct {
+	name                      string
+	skip                      bool
+	omitEmpty, redact, inline bool
+	named                     bool
+}
+
+func parseFieldTag(sf reflect.StructField) fieldTag {
+	var res fieldTag
+	tag, ok := sf.Tag.Lookup("L")
+	if !ok {
+		tag = sf.Tag.Get("json")
+		// only names and omitempty are meaningful in json tags.
+		name, opts, _ := strings.Cut(tag, ",")
+		tag = name
+		if hasOpt(opts, "omitempty") {
+			tag += ",omitempty"
+		}
+	}
+	if tag == "-" {
+		res.skip = true
+		return res
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	res.name = name
+	res.named = name != ""
+	if !res.named {
+		res.name = sf.Name
+	}
+	res.omitEmpty = hasOpt(opts, "omitempty")
+	res.redact = hasOpt(opts, "redact")
+	res.inline = hasOpt(opts, "inline")
+	return res
+}
+
+func hasOpt(opts, opt string) bool {
+	for opts != "" {
+		var o string
+		o, opts, _ = strings.Cut(opts, ",")
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// structFields computes the fields of struct type 'rt', with inlined
+// fields flattened.  If several fields have the same name, the least
+// deeply nested one is encoded, and among those the first.
+func structFields(rt reflect.Type) []structField {
+	var res []structField
+	depths := map[string]int{}
+	var walk func(rt reflect.Type, index []int, visited map[reflect.Type]bool)
+	walk = func(rt reflect.Type, index []int, visited map[reflect.Type]bool) {
+		if visited[rt] {
+			return
+		}
+		visited[rt] = true
+		defer delete(visited, rt)
+		for i := 0; i < rt.NumField(); i++ {
+			sf := rt.Field(i)
+			tag := parseFieldTag(sf)
+			if tag.skip {
+				continue
+			}
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer && ft.Name() == "" {
+				ft = ft.Elem()
+			}
+			idx := append(append([]int{}, index...), i)
+			inline := tag.inline || (sf.Anonymous && !tag.named)
+			if inline && ft.Kind() == reflect.Struct && !isSpecial(ft) {
+				walk(ft, idx, visited)
+				continue
+			}
+			if !sf.IsExported() {
+				continue
+			}
+			if d, ok := depths[tag.name]; ok && d <= len(idx) && !(tag.inline && ft.Kind() == reflect.Map) {
+				continue
+			}
+			f := structField{
+				name:      tag.name,
+				index:     idx,
+				omitEmpty: tag.omitEmpty,
+				redact:    tag.redact,
+				inlineMap: tag.inline && ft.Kind() == reflect.Map,
+			}
+			if !f.inlineMap {
+				depths[tag.name] = len(idx)
+				f.key = append((&Obj{}).Str(tag.name).D(), ':')
+				f.enc = typeEncoder(sf.Type)
+			}
+			res = append(res, f)
+		}
+	}
+	walk(rt, nil, map[reflect.Type]bool{})
+	// remove fields shadowed by a less nested one found later.
+	j := 0
+	for _, f := range res {
+		if !f.inlineMap && depths[f.name] != len(f.index) {
+			continue
+		}
+		res[j] = f
+		j++
+	}
+	return res[:j]
+}
@@ -0,0 +1,83 @@
+package L
+
+import (
+	"testing"
+	"time"
+)
+
+type Inner struct {
+	A int `L:"a"`
+	B string
+}
+
+type level int
+
+type user struct {
+	Inner
+	Name     string         `L:"name"`
+	Password string         `L:"password,redact"`
+	Token    string         `L:"token,redact,omitempty"`
+	Skip     int            `L:"-"`
+	Nick     string         `json:"nick,omitempty"`
+	Level    level          `L:"level"`
+	Extra    map[string]int `L:",inline"`
+	Tags     []string       `L:"tags,omitempty"`
+	When     time.Time      `L:"when"`
+	Meta     map[int]any    `L:"meta"`
+	Next     *user          `L:"next,omitempty"`
+	Opt      *Inner         `L:"opt,inline"`
+	private  int
+}
+
+func TestReflect(t *testing.T) {
+	u := &user{
+		Inner:    Inner{A: 1, B: "b"},
+		Name:     "bob",
+		Password: "secret",
+		Skip:     3,
+		Level:    2,
+		Extra:    map[string]int{"z": 26, "y": 25},
+		When:     time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC),
+		Meta:     map[int]any{10: []int{1, 2}, 2: nil},
+		Next:     &user{Name: "alice"},
+		private:  4,
+	}
+	o := (&Obj{}).Dict().Field("u", u)
+	if err := o.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"u":{"a":1,"B":"b","name":"bob","password":"[redacted]","level":2,"y":25,"z":26,` +
+		`"when":"2022-01-02T03:04:05Z","meta":{"10":[1,2],"2":null},` +
+		`"next":{"a":0,"B":"","name":"alice","password":"[redacted]","level":0,` +
+		`"when":"0001-01-01T00:00:00Z","meta":null}}}`
+	if got := string(o.D()); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+type cyclic struct {
+	Next *cyclic
+}
+
+func TestReflectCycle(t *testing.T) {
+	c := &cyclic{}
+	c.Next = c
+	o := (&Obj{}).Dict().Field("c", c)
+	if err := o.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReflectSlices(t *testing.T) {
+	o := (&Obj{}).Array().
+		Any([]any{1, "a", 2.5, nil, []byte("x")}).
+		Any([2]bool{true, false}).
+		Any([]level(nil))
+	if err := o.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := `[[1,"a",2.5,null,"eA=="],[true,false],null]`
+	if got := string(o.D()); got != want {
+		t.Errorf("got %s want %s", got, want)
+	}
+}
@@ -0,0 +1,318 @@
+package L
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Spec describes a registered Fmter, writer or Middleware by the name
+// under which its factory is registered and the json parameters given to
+// the factory.
+type Spec struct {
+	Name   string          `json:"name"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// FmterFactory creates a Fmter from json parameters, which may be empty.
+type FmterFactory func(params json.RawMessage) (Fmter, error)
+
+// WriterFactory creates a writer from json parameters, which may be empty.
+type WriterFactory func(params json.RawMessage) (io.Writer, error)
+
+// MiddlewareFactory creates a Middleware from json parameters, which may
+// be empty.
+type MiddlewareFactory func(params json.RawMessage) (Middleware, error)
+
+type registry struct {
+	mu    sync.RWMutex
+	fmter map[string]FmterFactory
+	w     map[string]WriterFactory
+	mw    map[string]MiddlewareFactory
+}
+
+var reg = &registry{
+	fmter: map[string]FmterFactory{},
+	w:     map[string]WriterFactory{},
+	mw:    map[string]MiddlewareFactory{},
+}
+
+// RegisterFmter registers 'f' as the factory of Fmters named 'name' in
+// Specs.  It panics if 'name' is already registered.
+func RegisterFmter(name string, f FmterFactory) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if _, ok := reg.fmter[name]; ok {
+		panic(fmt.Sprintf("L: fmter %q registered twice", name))
+	}
+	reg.fmter[name] = f
+}
+
+// RegisterWriter registers 'f' as the factory of writers named 'name' in
+// Specs.  It panics if 'name' is already registered.
+func RegisterWriter(name string, f WriterFactory) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if _, ok := reg.w[name]; ok {
+		panic(fmt.Sprintf("L: writer %q registered twice", name))
+	}
+	reg.w[name] = f
+}
+
+// RegisterMiddleware registers 'f' as the factory of Middlewares named
+// 'name' in Specs.  It panics if 'name' is already registered.
+func RegisterMiddleware(name string, f MiddlewareFactory) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if _, ok := reg.mw[name]; ok {
+		panic(fmt.Sprintf("L: middleware %q registered twice", name))
+	}
+	reg.mw[name] = f
+}
+
+// Registered returns the sorted names of the registered Fmters, writers
+// and Middlewares.
+func Registered() (fmters, writers, middlewares []string) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	for k := range reg.fmter {
+		fmters = append(fmters, k)
+	}
+	for k := range reg.w {
+		writers = append(writers, k)
+	}
+	for k := range reg.mw {
+		middlewares = append(middlewares, k)
+	}
+	sort.Strings(fmters)
+	sort.Strings(writers)
+	sort.Strings(middlewares)
+	return
+}
+
+// NewFmter creates the Fmter described by 's'.
+func NewFmter(s *Spec) (Fmter, error) {
+	reg.mu.RLock()
+	f := reg.fmter[s.Name]
+	reg.mu.RUnlock()
+	if f == nil {
+		return nil, fmt.Errorf("unregistered fmter %q", s.Name)
+	}
+	res, err := f(s.Params)
+	if err != nil {
+		return nil, fmt.Errorf("fmter %q: %w", s.Name, err)
+	}
+	return res, nil
+}
+
+// NewWriter creates the writer described by 's'.
+func NewWriter(s *Spec) (io.Writer, error) {
+	reg.mu.RLock()
+	f := reg.w[s.Name]
+	reg.mu.RUnlock()
+	if f == nil {
+		return nil, fmt.Errorf("unregistered writer %q", s.Name)
+	}
+	res, err := f(s.Params)
+	if err != nil {
+		return nil, fmt.Errorf("writer %q: %w", s.Name, err)
+	}
+	return res, nil
+}
+
+// NewMiddleware creates the Middleware described by 's'.
+func NewMiddleware(s *Spec) (Middleware, error) {
+	reg.mu.RLock()
+	f := reg.mw[s.Name]
+	reg.mu.RUnlock()
+	if f == nil {
+		return nil, fmt.Errorf("unregistered middleware %q", s.Name)
+	}
+	res, err := f(s.Params)
+	if err != nil {
+		return nil, fmt.Errorf("middleware %q: %w", s.Name, err)
+	}
+	return res, nil
+}
+
+func newMiddlewares(ss []Spec) ([]Middleware, error) {
+	res := make([]Middleware, 0, len(ss))
+	for i := range ss {
+		mw, err := NewMiddleware(&ss[i])
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, mw)
+	}
+	return res, nil
+}
+
+// DecodeParams decodes the json parameters 'p' of a Spec into 'v',
+// leaving 'v' untouched if 'p' is empty or null.  Unknown fields are an
+// error.
+func DecodeParams(p json.RawMessage, v any) error {
+	p = bytes.TrimSpace(p)
+	if len(p) == 0 || string(p) == "null" {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(p))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// fmterOf returns a FmterFactory decoding its parameters into a new T.
+func fmterOf[T any, P interface {
+	*T
+	Fmter
+}]() FmterFactory {
+	return func(p json.RawMessage) (Fmter, error) {
+		var v P = new(T)
+		if err := DecodeParams(p, v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+}
+
+// files are the files opened by the "file" writer, by path, so that
+// applying a configuration again does not open them again.
+var files = struct {
+	sync.Mutex
+	m map[string]*sharedFile
+}{m: map[string]*sharedFile{}}
+
+// sharedFile is a file opened by the "file" writer.  Closing it removes
+// it from files, so that it is opened again when next used.
+type sharedFile struct {
+	*os.File
+}
+
+func (f *sharedFile) Close() error {
+	files.Lock()
+	if files.m[f.Name()] == f {
+		delete(files.m, f.Name())
+	}
+	files.Unlock()
+	return f.File.Close()
+}
+
+func openFile(path string) (*sharedFile, error) {
+	files.Lock()
+	defer files.Unlock()
+	if f, ok := files.m[path]; ok {
+		return f, nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	sf := &sharedFile{File: f}
+	files.m[path] = sf
+	return sf, nil
+}
+
+type labelParams struct {
+	Label string `json:"label"`
+	Value int    `json:"value"`
+}
+
+// labelMiddleware returns a MiddlewareFactory with a labelParams parameter
+// whose label is localized to the package of the config at each call.
+func labelMiddleware(fn func(label string, value int) Middleware) MiddlewareFactory {
+	return func(p json.RawMessage) (Middleware, error) {
+		var lp labelParams
+		if err := DecodeParams(p, &lp); err != nil {
+			return nil, err
+		}
+		if lp.Label == "" {
+			return nil, fmt.Errorf("no label")
+		}
+		if lp.Label[0] != '.' {
+			return fn(lp.Label, lp.Value), nil
+		}
+		return func(cfg *Config, o *Obj) *Obj {
+			return fn(cfg.Unlocalize(lp.Label), lp.Value)(cfg, o)
+		}, nil
+	}
+}
+
+func init() {
+	RegisterFmter("json", func(json.RawMessage) (Fmter, error) {
+		return JSONFmter(), nil
+	})
+	RegisterFmter("table", fmterOf[TableFmter]())
+	RegisterFmter("logfmt", fmterOf[LogfmtFmter]())
+	RegisterFmter("console", fmterOf[ConsoleFmter]())
+	RegisterFmter("syslog", fmterOf[SyslogFmter]())
+	RegisterFmter("otlp", fmterOf[OTLPFmter]())
+	RegisterFmter("gelf", fmterOf[GELFFmter]())
+	RegisterFmter("ecs", fmterOf[ECSFmter]())
+	RegisterFmter("template", func(p json.RawMessage) (Fmter, error) {
+		var tp struct {
+			Text string `json:"text"`
+		}
+		if err := DecodeParams(p, &tp); err != nil {
+			return nil, err
+		}
+		return NewTemplateFmter(tp.Text)
+	})
+
+	RegisterWriter("stderr", func(json.RawMessage) (io.Writer, error) {
+		return os.Stderr, nil
+	})
+	RegisterWriter("stdout", func(json.RawMessage) (io.Writer, error) {
+		return os.Stdout, nil
+	})
+	RegisterWriter("discard", func(json.RawMessage) (io.Writer, error) {
+		return io.Discard, nil
+	})
+	RegisterWriter("file", func(p json.RawMessage) (io.Writer, error) {
+		var fp struct {
+			Path string `json:"path"`
+		}
+		if err := DecodeParams(p, &fp); err != nil {
+			return nil, err
+		}
+		if fp.Path == "" {
+			return nil, fmt.Errorf("no path")
+		}
+		return openFile(fp.Path)
+	})
+
+	RegisterMiddleware("pkg", func(json.RawMessage) (Middleware, error) {
+		return func(cfg *Config, o *Obj) *Obj {
+			return o.Field("Lpkg", cfg.Package())
+		}, nil
+	})
+	RegisterMiddleware("time", func(p json.RawMessage) (Middleware, error) {
+		tp := struct {
+			Key    string `json:"key"`
+			Format string `json:"format"`
+		}{Key: "time", Format: "2006-01-02T15:04:05.000Z07:00"}
+		if err := DecodeParams(p, &tp); err != nil {
+			return nil, err
+		}
+		return TimeFormat(tp.Key, tp.Format), nil
+	})
+	RegisterMiddleware("if", labelMiddleware(func(l string, _ int) Middleware {
+		return If(l)
+	}))
+	RegisterMiddleware("ifNot", labelMiddleware(func(l string, _ int) Middleware {
+		return IfNot(l)
+	}))
+	RegisterMiddleware("leq", labelMiddleware(Leq))
+	RegisterMiddleware("geq", labelMiddleware(Geq))
+	RegisterMiddleware("label", labelMiddleware(func(l string, _ int) Middleware {
+		return Label(l)
+	}))
+	RegisterMiddleware("caller", func(json.RawMessage) (Middleware, error) {
+		return Caller(), nil
+	})
+	RegisterMiddleware("stackOnErr", func(json.RawMessage) (Middleware, error) {
+		return StackOnErr(), nil
+	})
+}
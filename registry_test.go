@@ -0,0 +1,126 @@
+package L_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/scott-cotton/L"
+)
+
+var registryW = bytes.NewBuffer(nil)
+
+func init() {
+	L.RegisterWriter("test-buffer", func(json.RawMessage) (io.Writer, error) {
+		return registryW, nil
+	})
+}
+
+func TestRegistry(t *testing.T) {
+	w := registryW
+	w.Reset()
+	var cfg L.Config
+	err := json.Unmarshal([]byte(`{
+		"labels": {".debug": 1},
+		"post": [{"name": "if", "params": {"label": ".debug"}}, {"name": "pkg"}],
+		"w": {"name": "test-buffer"},
+		"f": {"name": "table", "params": {"fields": ["Lpkg", "msg"]}}
+	}`), &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Resolve(); err != nil {
+		t.Fatal(err)
+	}
+	l := L.New(L.NewConfig())
+	defer l.Close()
+	l.ApplyConfig(&cfg, nil)
+	l.Dict().Field("msg", "hi").Log()
+	if got, want := w.String(), "github.com/scott-cotton/L_test hi\n"; got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+	d, err := json.Marshal(l.ReadConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(d, []byte(`"f":{"name":"table","params":{"fields":["Lpkg","msg"]}}`)) {
+		t.Errorf("config json: %s", d)
+	}
+
+	for _, bad := range []string{
+		`{"f": {"name": "nope"}}`,
+		`{"f": {"name": "table", "params": {"Nope": 1}}}`,
+		`{"post": [{"name": "if"}]}`,
+		`{"sinks": [{"name": "s", "w": {"name": "file"}}]}`,
+	} {
+		var cfg L.Config
+		if err := json.Unmarshal([]byte(bad), &cfg); err != nil {
+			t.Fatal(err)
+		}
+		if err := cfg.Resolve(); err == nil {
+			t.Errorf("%s: no error", bad)
+		} else if cfg.F != nil || cfg.Post != nil {
+			t.Errorf("%s: config changed on error", bad)
+		}
+	}
+
+	fmters, writers, mws := L.Registered()
+	all := strings.Join(fmters, " ") + "|" + strings.Join(writers, " ") + "|" + strings.Join(mws, " ")
+	for _, name := range []string{"table", "test-buffer", "geq"} {
+		if !strings.Contains(all, name) {
+			t.Errorf("%s not in %s", name, all)
+		}
+	}
+}
+
+func TestFileWriterSpec(t *testing.T) {
+	p, _ := json.Marshal(map[string]string{"path": filepath.Join(t.TempDir(), "app.log")})
+	w, err := L.NewWriter(&L.Spec{Name: "file", Params: p})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w2, err := L.NewWriter(&L.Spec{Name: "file", Params: p}); err != nil || w2 != w {
+		t.Errorf("not reused: %v", err)
+	}
+	if err := w.(io.Closer).Close(); err != nil {
+		t.Fatal(err)
+	}
+	w2, err := L.NewWriter(&L.Spec{Name: "file", Params: p})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w2.(io.Closer).Close()
+	if w2 == w {
+		t.Error("closed file reused")
+	}
+	if _, err := w2.Write([]byte("x\n")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTableSpec(t *testing.T) {
+	f, err := L.NewFmter(&L.Spec{Name: "table", Params: []byte(`{"fields": ["x"], "floatFmt": "f", "floatPrec": 2}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := f.Fmt(&buf, []byte(`{"x":1.234}`)); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "1.23\n" {
+		t.Errorf("got %q", got)
+	}
+	d, err := json.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(d), `{"fields":["x"],"floatPrec":2,"floatFmt":"f"}`; got != want {
+		t.Errorf("got %s want %s", got, want)
+	}
+	if _, err := L.NewFmter(&L.Spec{Name: "table", Params: []byte(`{"floatFmt": "q"}`)}); err == nil {
+		t.Error("no error for invalid floatFmt")
+	}
+}
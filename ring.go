@@ -0,0 +1,248 @@
+package L
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RingRecord is a record kept by a RingSink.
+type RingRecord struct {
+	// Time is the time at which the record was logged.
+	Time time.Time `json:"time"`
+	// Package is the package of the logger.
+	Package string `json:"package"`
+	// Labels are the labels of the logger, localized as in ConfigTree.
+	Labels map[string]int `json:"labels,omitempty"`
+	// Record is the logged object.
+	Record json.RawMessage `json:"record"`
+}
+
+// RingQuery selects records of a RingSink.
+type RingQuery struct {
+	// PkgPattern is a regular expression matching the packages of the
+	// records, all if empty.
+	PkgPattern string `json:"pkgPattern,omitempty"`
+	// Labels are labels which the loggers of the records must have,
+	// such as ".debug".
+	Labels []string `json:"labels,omitempty"`
+	// Since and Until, if not zero, bound the times of the records.
+	Since time.Time `json:"since,omitempty"`
+	Until time.Time `json:"until,omitempty"`
+	// Limit, if positive, is the number of most recent records
+	// returned.
+	Limit int `json:"limit,omitempty"`
+}
+
+// RingSink is a ConfigFmter which keeps the most recent records of each
+// package in memory, so that they may be queried, for example over the
+// "recent" method of the rpc package.  It writes nothing to its writer,
+// and is normally used in a Sink given by RingSink.Sink.
+//
+// A RingSink may be shared by several loggers.
+type RingSink struct {
+	maxRecords, maxBytes int
+
+	mu   sync.Mutex
+	pkgs map[string]*ring
+}
+
+// ring is the records of a package, oldest first, from recs[head].
+type ring struct {
+	recs   []RingRecord
+	head   int
+	bytes  int
+	labels map[string]int
+}
+
+// NewRingSink creates a RingSink keeping, for each package, at most
+// 'maxRecords' records and, if 'maxBytes' is positive, records with at
+// most 'maxBytes' bytes in total.
+func NewRingSink(maxRecords, maxBytes int) *RingSink {
+	if maxRecords <= 0 {
+		maxRecords = 1
+	}
+	return &RingSink{
+		maxRecords: maxRecords,
+		maxBytes:   maxBytes,
+		pkgs:       map[string]*ring{},
+	}
+}
+
+// Sink returns a Sink named 'name' keeping records in 'r'.
+func (r *RingSink) Sink(name string) Sink {
+	return Sink{Name: name, W: io.Discard, F: r}
+}
+
+// Fmt keeps 'd' as a record of the package "".
+func (r *RingSink) Fmt(w io.Writer, d []byte) error {
+	return r.FmtConfig(&Config{}, w, d)
+}
+
+// FmtConfig keeps 'd' as a record of the logger with config 'cfg'.
+func (r *RingSink) FmtConfig(cfg *Config, _ io.Writer, d []byte) error {
+	now := time.Now()
+	pkg := cfg.Package()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	g := r.pkgs[pkg]
+	if g == nil {
+		g = &ring{}
+		r.pkgs[pkg] = g
+	}
+	if !g.sameLabels(cfg) {
+		g.labels = make(map[string]int, len(cfg.Labels))
+		for k, v := range cfg.Labels {
+			g.labels[cfg.Localize(k)] = v
+		}
+	}
+	rec := RingRecord{
+		Time:    now,
+		Package: pkg,
+		Labels:  g.labels,
+		Record:  append(json.RawMessage(nil), d...),
+	}
+	g.add(rec, r.maxRecords, r.maxBytes)
+	return nil
+}
+
+// sameLabels returns whether the labels of 'cfg' are those of the last
+// record, so that their copy may be shared.
+func (g *ring) sameLabels(cfg *Config) bool {
+	if g.labels == nil || len(g.labels) != len(cfg.Labels) {
+		return false
+	}
+	for k, v := range cfg.Labels {
+		if w, ok := g.labels[cfg.Localize(k)]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *ring) add(rec RingRecord, maxRecords, maxBytes int) {
+	g.recs = append(g.recs, rec)
+	g.bytes += len(rec.Record)
+	for g.head < len(g.recs) && (len(g.recs)-g.head > maxRecords ||
+		(maxBytes > 0 && g.bytes > maxBytes)) {
+		g.bytes -= len(g.recs[g.head].Record)
+		g.recs[g.head] = RingRecord{}
+		g.head++
+	}
+	if g.head > len(g.recs)/2 {
+		n := copy(g.recs, g.recs[g.head:])
+		for i := n; i < len(g.recs); i++ {
+			g.recs[i] = RingRecord{}
+		}
+		g.recs = g.recs[:n]
+		g.head = 0
+	}
+}
+
+func (g *ring) each(fn func(*RingRecord)) {
+	for i := g.head; i < len(g.recs); i++ {
+		fn(&g.recs[i])
+	}
+}
+
+// Query returns the records selected by 'q', oldest first.
+func (r *RingSink) Query(q *RingQuery) ([]RingRecord, error) {
+	var pkgRe *regexp.Regexp
+	if q.PkgPattern != "" {
+		var err error
+		if pkgRe, err = regexp.Compile(q.PkgPattern); err != nil {
+			return nil, err
+		}
+	}
+	var res []RingRecord
+	r.mu.Lock()
+	for pkg, g := range r.pkgs {
+		if pkgRe != nil && !pkgRe.MatchString(pkg) {
+			continue
+		}
+		g.each(func(rec *RingRecord) {
+			if q.match(rec) {
+				res = append(res, *rec)
+			}
+		})
+	}
+	r.mu.Unlock()
+	return LimitRecords(res, q.Limit), nil
+}
+
+func (q *RingQuery) match(rec *RingRecord) bool {
+	if !q.Since.IsZero() && rec.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && rec.Time.After(q.Until) {
+		return false
+	}
+	for _, lbl := range q.Labels {
+		if _, ok := rec.Labels[lbl]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// LimitRecords sorts 'recs' by time and returns the 'limit' most recent,
+// or all of them if 'limit' is not positive.
+func LimitRecords(recs []RingRecord, limit int) []RingRecord {
+	sort.SliceStable(recs, func(i, j int) bool {
+		return recs[i].Time.Before(recs[j].Time)
+	})
+	if limit > 0 && len(recs) > limit {
+		recs = recs[len(recs)-limit:]
+	}
+	return recs
+}
+
+// RingSinks returns the RingSinks which are the Fmters of loggers or of
+// their Sinks.
+func RingSinks() []*RingSink {
+	var res []*RingSink
+	seen := map[*RingSink]bool{}
+	add := func(f Fmter) {
+		if r, ok := f.(*RingSink); ok && !seen[r] {
+			seen[r] = true
+			res = append(res, r)
+		}
+	}
+	Walk(func(cfg *Config) {
+		add(cfg.F)
+		for i := range cfg.Sinks {
+			add(cfg.Sinks[i].F)
+		}
+	})
+	return res
+}
+
+// rings are the RingSinks created by the "ring" Fmter, by name.
+var rings = struct {
+	sync.Mutex
+	m map[string]*RingSink
+}{m: map[string]*RingSink{}}
+
+func init() {
+	RegisterFmter("ring", func(p json.RawMessage) (Fmter, error) {
+		rp := struct {
+			Name       string `json:"name"`
+			MaxRecords int    `json:"maxRecords"`
+			MaxBytes   int    `json:"maxBytes"`
+		}{MaxRecords: 1000}
+		if err := DecodeParams(p, &rp); err != nil {
+			return nil, err
+		}
+		rings.Lock()
+		defer rings.Unlock()
+		r := rings.m[rp.Name]
+		if r == nil {
+			r = NewRingSink(rp.MaxRecords, rp.MaxBytes)
+			rings.m[rp.Name] = r
+		}
+		return r, nil
+	})
+}
@@ -0,0 +1,85 @@
+package L_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/scott-cotton/L"
+)
+
+func TestRingSink(t *testing.T) {
+	r := L.NewRingSink(3, 40)
+	cfg := L.NewConfig(".a")
+	cfg.Sinks = []L.Sink{r.Sink("ring")}
+	l := L.New(cfg)
+	defer l.Close()
+	dbg := l.With(".debug", 1)
+	defer dbg.Close()
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		l.Dict().Field("i", i).Log()
+	}
+	dbg.Dict().Field("d", 1).Log()
+
+	recs, err := r.Query(&L.RingQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, rec := range recs {
+		got = append(got, string(rec.Record))
+		if rec.Package != "github.com/scott-cotton/L_test" || rec.Time.Before(start) {
+			t.Errorf("record %+v", rec)
+		}
+	}
+	if fmt.Sprint(got) != `[{"i":3} {"i":4} {"d":1}]` {
+		t.Errorf("got %s", got)
+	}
+
+	for _, tc := range []struct {
+		q    L.RingQuery
+		want int
+	}{
+		{L.RingQuery{Labels: []string{".debug"}}, 1},
+		{L.RingQuery{Labels: []string{".a"}}, 3},
+		{L.RingQuery{Limit: 2}, 2},
+		{L.RingQuery{PkgPattern: "^other$"}, 0},
+		{L.RingQuery{Since: time.Now().Add(time.Hour)}, 0},
+		{L.RingQuery{Until: start.Add(-time.Hour)}, 0},
+	} {
+		recs, err := r.Query(&tc.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(recs) != tc.want {
+			t.Errorf("%+v: got %d records want %d", tc.q, len(recs), tc.want)
+		}
+	}
+	if _, err := r.Query(&L.RingQuery{PkgPattern: "("}); err == nil {
+		t.Errorf("no error for invalid pattern")
+	}
+
+	// byte bound: 3 records of 12 bytes do not fit in 40.
+	for i := 0; i < 3; i++ {
+		l.Dict().Field("x", 10000+i).Log()
+	}
+	recs, _ = r.Query(&L.RingQuery{})
+	if len(recs) != 3 || string(recs[0].Record) != `{"x":10000}` {
+		t.Errorf("got %d records", len(recs))
+	}
+	l.Dict().Field("long", "0123456789012345678901234567890123456789").Log()
+	recs, _ = r.Query(&L.RingQuery{})
+	if len(recs) != 0 {
+		t.Errorf("got %d records", len(recs))
+	}
+
+	found := false
+	for _, rs := range L.RingSinks() {
+		found = found || rs == r
+	}
+	if !found {
+		t.Errorf("RingSinks does not contain the sink")
+	}
+}
@@ -0,0 +1,490 @@
+package L
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// rotateLayout is the layout of the time in the names of segments, which
+// sort in time order.
+const rotateLayout = "20060102T150405.000000000Z"
+
+// RotatingFile is an io.WriteCloser appending to a file which it rotates
+// into segments by size, by wall-clock interval or on demand.
+//
+// A segment is the file renamed to its path followed by "." and the UTC
+// time of rotation, such as "app.log.20240102T150405.000000000Z", and, if
+// Compress is set, compressed with gzip in the background to the segment
+// name followed by ".gz".  Compression writes to a temporary file which
+// is renamed when complete, so that a crash leaves either the segment or
+// its compressed form; segments left uncompressed are compressed on the
+// next rotation.
+//
+// The fields should be set before the first write.  Errors in
+// compressing or removing segments are returned by the next call to
+// Write, after writing, or to Rotate or Close.  If the file cannot be
+// closed or reopened in rotating or in Reopen, the error is returned and
+// the file is opened again by the next call to Write or Rotate.
+//
+// A RotatingFile is safe for concurrent use.
+type RotatingFile struct {
+	// MaxSize, if positive, rotates the file before a write would make
+	// it larger than MaxSize bytes.
+	MaxSize int64
+	// Interval, if positive, rotates the file at the first write after
+	// each multiple of Interval since the zero time, such as at each
+	// hour or each midnight UTC.  A file which is not empty when first
+	// written is taken to start at its modification time, so that it is
+	// rotated if that is in an earlier interval.
+	Interval time.Duration
+	// Compress compresses segments with gzip.
+	Compress bool
+	// MaxSegments, if positive, is the number of segments kept.
+	MaxSegments int
+	// MaxAge, if positive, is the age after which segments are removed.
+	MaxAge time.Duration
+
+	path   string
+	mu     sync.Mutex
+	f      *os.File // nil if closed or if reopening failed
+	closed bool
+	size   int64
+	next   time.Time
+	err    error
+	wg     sync.WaitGroup
+	sig    chan os.Signal
+	now    func() time.Time
+}
+
+// OpenRotatingFile opens a RotatingFile appending to the file 'path',
+// which is created if it does not exist.
+func OpenRotatingFile(path string) (*RotatingFile, error) {
+	r := &RotatingFile{path: path, now: time.Now}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f = f
+	r.size = st.Size()
+	return nil
+}
+
+// Path returns the path of the file.
+func (r *RotatingFile) Path() string {
+	return r.path
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.reopened(); err != nil {
+		return 0, err
+	}
+	now := r.now()
+	rotate := r.MaxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.MaxSize
+	if r.Interval > 0 {
+		if r.next.IsZero() {
+			r.next = r.start(now).Truncate(r.Interval).Add(r.Interval)
+		}
+		if !now.Before(r.next) {
+			rotate = true
+			r.next = now.Truncate(r.Interval).Add(r.Interval)
+		}
+	}
+	if rotate && r.size > 0 {
+		if err := r.rotate(now); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	if err == nil && r.err != nil {
+		err = r.err
+		r.err = nil
+	}
+	return n, err
+}
+
+// reopened opens the file if it is not open, returning ErrClosed if 'r'
+// is closed.
+func (r *RotatingFile) reopened() error {
+	if r.closed {
+		return ErrClosed
+	}
+	if r.f == nil {
+		return r.open()
+	}
+	return nil
+}
+
+// start returns the time at which the current file was started: its
+// modification time if it is not empty, and otherwise 'now'.
+func (r *RotatingFile) start(now time.Time) time.Time {
+	if r.size == 0 {
+		return now
+	}
+	st, err := r.f.Stat()
+	if err != nil {
+		return now
+	}
+	return st.ModTime()
+}
+
+// Rotate rotates the file, unless it is empty.
+func (r *RotatingFile) Rotate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.reopened(); err != nil {
+		return err
+	}
+	if err := r.err; err != nil {
+		r.err = nil
+		return err
+	}
+	if r.size == 0 {
+		return nil
+	}
+	return r.rotate(r.now())
+}
+
+// Reopen closes and reopens the file, for use after it is moved by an
+// external tool such as logrotate.
+func (r *RotatingFile) Reopen() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return ErrClosed
+	}
+	if r.f != nil {
+		r.f.Close()
+		r.f = nil
+	}
+	return r.open()
+}
+
+// ReopenOnSignal calls Reopen whenever one of the signals 'sigs', or
+// SIGHUP if there are none, is received, until 'r' is closed or
+// StopSignal is called.  Errors in reopening are returned by the next
+// call to Write, Rotate or Close.
+func (r *RotatingFile) ReopenOnSignal(sigs ...os.Signal) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGHUP}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sig != nil || r.closed {
+		return
+	}
+	r.sig = make(chan os.Signal, 1)
+	signal.Notify(r.sig, sigs...)
+	go func(c chan os.Signal) {
+		for range c {
+			if err := r.Reopen(); err != nil && err != ErrClosed {
+				r.setErr(err)
+			}
+		}
+	}(r.sig)
+}
+
+// StopSignal stops the reopening started by ReopenOnSignal.
+func (r *RotatingFile) StopSignal() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopSignal()
+}
+
+func (r *RotatingFile) stopSignal() {
+	if r.sig != nil {
+		signal.Stop(r.sig)
+		close(r.sig)
+		r.sig = nil
+	}
+}
+
+// Close closes the file, waiting for the compression of segments.
+func (r *RotatingFile) Close() error {
+	rotatingFiles.Lock()
+	if rotatingFiles.m[r.path] == r {
+		delete(rotatingFiles.m, r.path)
+	}
+	rotatingFiles.Unlock()
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return ErrClosed
+	}
+	r.closed = true
+	r.stopSignal()
+	var err error
+	if r.f != nil {
+		err = r.f.Close()
+		r.f = nil
+	}
+	r.mu.Unlock()
+	r.wg.Wait()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		err = r.err
+		r.err = nil
+	}
+	return err
+}
+
+func (r *RotatingFile) setErr(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// rotate renames the file to a new segment, reopens it, and compresses
+// and removes segments in the background.  If it fails to close or
+// reopen the file, r.f is left nil for the next write to reopen it.
+func (r *RotatingFile) rotate(now time.Time) error {
+	t := now.UTC()
+	seg := r.path + "." + t.Format(rotateLayout)
+	for exists(seg) || exists(seg+".gz") {
+		t = t.Add(time.Nanosecond)
+		seg = r.path + "." + t.Format(rotateLayout)
+	}
+	err := r.f.Close()
+	r.f = nil
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(r.path, seg); err != nil {
+		if oerr := r.open(); oerr != nil {
+			return oerr
+		}
+		return err
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	compress, maxSegs, maxAge := r.Compress, r.MaxSegments, r.MaxAge
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		if err := r.cleanup(now, compress, maxSegs, maxAge); err != nil {
+			r.setErr(err)
+		}
+	}()
+	return nil
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// segments returns the names of the segments of the file, oldest first.
+func (r *RotatingFile) segments() ([]string, error) {
+	dir, base := filepath.Split(r.path)
+	if dir == "" {
+		dir = "."
+	}
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var res []string
+	for _, ent := range ents {
+		name := ent.Name()
+		if !strings.HasPrefix(name, base+".") || ent.IsDir() {
+			continue
+		}
+		if _, ok := segmentTime(name[len(base)+1:]); ok {
+			res = append(res, filepath.Join(dir, name))
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// segmentTime parses the time from the suffix 's' of the name of a
+// segment, which may be a temporary file of compression.
+func segmentTime(s string) (time.Time, bool) {
+	if len(s) < len(rotateLayout) {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(rotateLayout, s[:len(rotateLayout)])
+	return t, err == nil
+}
+
+var rotateMu sync.Mutex
+
+// cleanup compresses segments and removes those which are too old or too
+// many.
+func (r *RotatingFile) cleanup(now time.Time, compress bool, maxSegs int, maxAge time.Duration) error {
+	// cleanups of the same or different files do not run concurrently.
+	rotateMu.Lock()
+	defer rotateMu.Unlock()
+	segs, err := r.segments()
+	if err != nil {
+		return err
+	}
+	var keep []string
+	var firstErr error
+	for _, seg := range segs {
+		if strings.HasSuffix(seg, ".tmp") {
+			// left by an interrupted compression.
+			os.Remove(seg)
+			continue
+		}
+		if compress && !strings.HasSuffix(seg, ".gz") {
+			if err := gzipFile(seg); err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				keep = append(keep, seg)
+				continue
+			}
+			seg += ".gz"
+		}
+		keep = append(keep, seg)
+	}
+	base := filepath.Base(r.path)
+	for i, seg := range keep {
+		t, _ := segmentTime(filepath.Base(seg)[len(base)+1:])
+		old := maxAge > 0 && now.Sub(t) > maxAge
+		many := maxSegs > 0 && len(keep)-i > maxSegs
+		if !old && !many {
+			continue
+		}
+		if err := os.Remove(seg); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// gzipFile compresses 'path' to 'path.gz' and removes 'path'.
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp := path + ".gz.tmp"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	z := gzip.NewWriter(out)
+	_, err = io.Copy(z, in)
+	if err == nil {
+		err = z.Close()
+	}
+	if err == nil {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path+".gz")
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(path)
+}
+
+// rotatingFiles are the RotatingFiles opened by the "rotatingFile" writer,
+// by path.
+var rotatingFiles = struct {
+	sync.Mutex
+	m map[string]*RotatingFile
+}{m: map[string]*RotatingFile{}}
+
+type rotatingParams struct {
+	Path        string `json:"path"`
+	MaxSize     int64  `json:"maxSize"`
+	Interval    string `json:"interval"`
+	Compress    bool   `json:"compress"`
+	MaxSegments int    `json:"maxSegments"`
+	MaxAge      string `json:"maxAge"`
+	ReopenOnHUP bool   `json:"reopenOnHUP"`
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// newRotatingFile is the WriterFactory of "rotatingFile".  An open
+// RotatingFile with the same path is reused, with the new settings
+// replacing all the earlier ones.
+func newRotatingFile(p json.RawMessage) (io.Writer, error) {
+	var rp rotatingParams
+	if err := DecodeParams(p, &rp); err != nil {
+		return nil, err
+	}
+	if rp.Path == "" {
+		return nil, fmt.Errorf("no path")
+	}
+	interval, err := parseDuration(rp.Interval)
+	if err != nil {
+		return nil, err
+	}
+	maxAge, err := parseDuration(rp.MaxAge)
+	if err != nil {
+		return nil, err
+	}
+	rotatingFiles.Lock()
+	defer rotatingFiles.Unlock()
+	r := rotatingFiles.m[rp.Path]
+	if r == nil {
+		if r, err = OpenRotatingFile(rp.Path); err != nil {
+			return nil, err
+		}
+		rotatingFiles.m[rp.Path] = r
+	}
+	r.mu.Lock()
+	r.MaxSize = rp.MaxSize
+	if r.Interval != interval {
+		r.Interval = interval
+		r.next = time.Time{}
+	}
+	r.Compress = rp.Compress
+	r.MaxSegments = rp.MaxSegments
+	r.MaxAge = maxAge
+	r.mu.Unlock()
+	if rp.ReopenOnHUP {
+		r.ReopenOnSignal()
+	} else {
+		r.StopSignal()
+	}
+	return r, nil
+}
+
+func init() {
+	RegisterWriter("rotatingFile", newRotatingFile)
+}
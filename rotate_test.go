@@ -0,0 +1,230 @@
+package L
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readSegments(t *testing.T, path string) []string {
+	t.Helper()
+	ms, err := filepath.Glob(path + ".*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(ms)
+	var res []string
+	for _, m := range ms {
+		f, err := os.Open(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r io.Reader = f
+		if strings.HasSuffix(m, ".gz") {
+			if r, err = gzip.NewReader(f); err != nil {
+				t.Fatal(err)
+			}
+		}
+		d, err := io.ReadAll(r)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		res = append(res, string(d))
+	}
+	return res
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	r, err := OpenRotatingFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	r.MaxSize = 10
+	r.Interval = time.Hour
+	r.Compress = true
+	r.MaxSegments = 3
+
+	io.WriteString(r, "aaaa\n")
+	io.WriteString(r, "bbbb\n")
+	now = now.Add(time.Second)
+	io.WriteString(r, "cccc\n") // size
+	now = now.Add(time.Hour)
+	io.WriteString(r, "dd\n") // interval
+	now = now.Add(time.Second)
+	if err := r.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(r, "ee\n")
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := readSegments(t, path), []string{"aaaa\nbbbb\n", "cccc\n", "dd\n"}; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("segments %q want %q", got, want)
+	}
+	d, _ := os.ReadFile(path)
+	if string(d) != "ee\n" {
+		t.Errorf("file %q", d)
+	}
+	ms, _ := filepath.Glob(path + ".*")
+	for _, m := range ms {
+		if !strings.HasSuffix(m, ".gz") {
+			t.Errorf("uncompressed segment %s", m)
+		}
+	}
+
+	// retention by age, and an interrupted compression.
+	r, err = OpenRotatingFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(2 * time.Hour)
+	r.now = func() time.Time { return now }
+	r.MaxAge = 150 * time.Minute
+	os.WriteFile(path+".20240102T170000.000000000Z.gz.tmp", []byte("x"), 0644)
+	if err := r.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := readSegments(t, path), []string{"cccc\n", "dd\n", "ee\n"}; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("segments %q want %q", got, want)
+	}
+}
+
+func TestRotatingFileReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	r, err := OpenRotatingFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	io.WriteString(r, "a\n")
+	// as logrotate does.
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(r, "b\n")
+	d, _ := os.ReadFile(path)
+	if string(d) != "b\n" {
+		t.Errorf("file %q", d)
+	}
+}
+
+func TestRotatingFileReopenFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	r, err := OpenRotatingFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	// a directory in place of the file cannot be opened for writing.
+	if err := os.Mkdir(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reopen(); err == nil {
+		t.Fatal("no error reopening a directory")
+	}
+	if _, err := io.WriteString(r, "a\n"); err == nil || err == ErrClosed {
+		t.Errorf("write without a file: %v", err)
+	}
+	os.Remove(path)
+	if _, err := io.WriteString(r, "b\n"); err != nil {
+		t.Fatal(err)
+	}
+	d, _ := os.ReadFile(path)
+	if string(d) != "b\n" {
+		t.Errorf("file %q", d)
+	}
+}
+
+func TestRotatingFileSpec(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	p, _ := json.Marshal(map[string]any{"path": path, "maxSize": 100, "interval": "1h"})
+	w, err := NewWriter(&Spec{Name: "rotatingFile", Params: p})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := w.(*RotatingFile)
+	defer r.Close()
+	if r.MaxSize != 100 || r.Interval != time.Hour {
+		t.Errorf("settings %d %s", r.MaxSize, r.Interval)
+	}
+	p, _ = json.Marshal(map[string]any{"path": path, "reopenOnHUP": true})
+	w2, err := NewWriter(&Spec{Name: "rotatingFile", Params: p})
+	if err != nil || w2 != w {
+		t.Errorf("not reused: %v", err)
+	}
+	if r.MaxSize != 0 || r.Interval != 0 || r.sig == nil {
+		t.Errorf("settings %d %s %v", r.MaxSize, r.Interval, r.sig)
+	}
+	p, _ = json.Marshal(map[string]any{"path": path})
+	if _, err := NewWriter(&Spec{Name: "rotatingFile", Params: p}); err != nil {
+		t.Fatal(err)
+	}
+	if r.sig != nil {
+		t.Errorf("still reopening on SIGHUP")
+	}
+	r.Close()
+	w3, err := NewWriter(&Spec{Name: "rotatingFile", Params: p})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w3.(*RotatingFile).Close()
+	if w3 == w {
+		t.Errorf("closed file reused")
+	}
+	p, _ = json.Marshal(map[string]any{"path": path, "interval": "often"})
+	if _, err := NewWriter(&Spec{Name: "rotatingFile", Params: p}); err == nil {
+		t.Errorf("no error for bad interval")
+	}
+}
+
+func TestRotatingFileRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(path, now.Add(-time.Hour), now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	r, err := OpenRotatingFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.now = func() time.Time { return now }
+	r.Interval = time.Hour
+	io.WriteString(r, "new\n")
+	r.setErr(errors.New("cleanup"))
+	if n, err := io.WriteString(r, "more\n"); n != 5 || err == nil || err.Error() != "cleanup" {
+		t.Errorf("got %d %v", n, err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readSegments(t, path); len(got) != 1 || got[0] != "old\n" {
+		t.Errorf("segments %q", got)
+	}
+	d, _ := os.ReadFile(path)
+	if string(d) != "new\nmore\n" {
+		t.Errorf("file %q", d)
+	}
+}
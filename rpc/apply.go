@@ -23,6 +23,21 @@ func Apply(parms *ApplyParams) (ApplyResult, error) {
 	if err != nil {
 		return nil, fmt.Errorf("invalid params: %w", err)
 	}
+	if parms.Config == nil {
+		return nil, fmt.Errorf("invalid params: no config")
+	}
+	// resolve only if some package matches, as resolving opens files,
+	// dials connections and starts goroutines for the writers.
+	matched := false
+	L.Walk(func(cfg *L.Config) {
+		matched = matched || pkgRe.MatchString(cfg.Package())
+	})
+	if !matched {
+		return nil, nil
+	}
+	if err := parms.Config.Resolve(); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
 	var res ApplyResult
 	walk := func(cfg *L.Config) {
 		pkg := cfg.Package()
@@ -0,0 +1,66 @@
+package rpc
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/scott-cotton/L"
+)
+
+func TestApplySpec(t *testing.T) {
+	l := L.New(L.NewConfig())
+	defer l.Close()
+	path := filepath.Join(t.TempDir(), "log")
+	var parms ApplyParams
+	err := json.Unmarshal([]byte(`{
+		"pkgPattern": "^github.com/scott-cotton/L/rpc$",
+		"config": {
+			"labels": {".debug": 1},
+			"w": {"name": "file", "params": {"path": `+quote(path)+`}},
+			"f": {"name": "table", "params": {"fields": ["msg"]}}
+		}
+	}`), &parms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Apply(&parms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) == 0 || res[0].FSpec == nil || res[0].FSpec.Name != "table" {
+		t.Fatalf("result: %+v", res)
+	}
+	l.Dict().Field("msg", "hello").Log()
+	d, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(d), "hello\n"; got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+
+	// a pattern matching no package opens nothing.
+	other := filepath.Join(t.TempDir(), "other")
+	none := ApplyParams{
+		PkgPattern: "^nope$",
+		Config:     &L.Config{WSpec: &L.Spec{Name: "file", Params: json.RawMessage(`{"path":` + quote(other) + `}`)}},
+	}
+	if res, err := Apply(&none); err != nil || len(res) != 0 {
+		t.Errorf("applying to no package: %v %v", res, err)
+	}
+	if _, err := os.Stat(other); !os.IsNotExist(err) {
+		t.Errorf("applying to no package opened %s", other)
+	}
+
+	parms.Config = &L.Config{FSpec: &L.Spec{Name: "nope"}}
+	if _, err := Apply(&parms); err == nil {
+		t.Errorf("no error applying an unregistered fmter")
+	}
+}
+
+func quote(s string) string {
+	d, _ := json.Marshal(s)
+	return string(d)
+}
@@ -2,6 +2,7 @@ package rpc
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -21,74 +22,73 @@ func NewClient(key, addr string) (*Client, error) {
 }
 
 func (c *Client) Apply(params *ApplyParams) (*ApplyResult, error) {
-	c.Lock()
-	defer c.Unlock()
-	req, err := NewRequest[ApplyParams](c.getID(), "apply", params)
-	if err != nil {
-		return nil, fmt.Errorf("error constructing jsonrpc request: %w", err)
-	}
-	buf := bytes.NewBuffer(nil)
-	if err := toWriter(c.key, buf, req); err != nil {
-		panic(err)
+	res := &ApplyResult{}
+	if err := c.call("apply", params, res); err != nil {
+		return nil, err
 	}
-	hReq, err := http.NewRequest("POST", c.addr, buf)
+	return res, nil
+}
 
-	if err != nil {
-		return nil, fmt.Errorf("error constructing HTTP request: %w", err)
+func (c *Client) Loggers() (*LoggersResult, error) {
+	res := &LoggersResult{}
+	if err := c.call("loggers", "", res); err != nil {
+		return nil, err
 	}
-	hReq.Header.Add("Accept", "application/json")
-	hReq.Header.Add("Content-type", "application/json")
-	h := &http.Client{}
-	resp, err := h.Do(hReq)
-	if err != nil {
-		return nil, fmt.Errorf("error performing http request: %w", err)
+	return res, nil
+}
+
+func (c *Client) Registered() (*RegisteredResult, error) {
+	res := &RegisteredResult{}
+	if err := c.call("registered", "", res); err != nil {
+		return nil, err
 	}
-	defer resp.Body.Close()
-	gResp, err := fromReader[Response](c.key, resp.Body)
-	if err != nil {
-		if gResp != nil && gResp.Error != nil {
-			return nil, errors.New(gResp.Error.Message)
-		}
-		return nil, fmt.Errorf("error decoding http response: %w", err)
+	return res, nil
+}
+
+func (c *Client) Recent(params *RecentParams) (*RecentResult, error) {
+	res := &RecentResult{}
+	if err := c.call("recent", params, res); err != nil {
+		return nil, err
 	}
-	return Result[ApplyResult](gResp)
+	return res, nil
 }
 
-func (c *Client) Loggers() (*LoggersResult, error) {
+// call calls 'method' of the server with 'params', decoding the result
+// into 'result'.
+func (c *Client) call(method string, params, result any) error {
 	c.Lock()
 	defer c.Unlock()
-	// construct LoggersRequest
-	pat := ""
-	req, err := NewRequest[string](c.getID(), "loggers", &pat)
+	req, err := NewRequest(c.getID(), method, &params)
 	if err != nil {
-		return nil, fmt.Errorf("error constructing jsonrpc request: %w", err)
+		return fmt.Errorf("error constructing jsonrpc request: %w", err)
 	}
 	buf := bytes.NewBuffer(nil)
 	if err := toWriter(c.key, buf, req); err != nil {
-		panic(err)
+		return fmt.Errorf("error signing jsonrpc request: %w", err)
 	}
-
 	hReq, err := http.NewRequest("POST", c.addr, buf)
-
 	if err != nil {
-		return nil, fmt.Errorf("error constructing HTTP request: %w", err)
+		return fmt.Errorf("error constructing HTTP request: %w", err)
 	}
 	hReq.Header.Add("Accept", "application/json")
 	hReq.Header.Add("Content-type", "application/json")
 	h := &http.Client{}
 	resp, err := h.Do(hReq)
 	if err != nil {
-		return nil, fmt.Errorf("error performing http request: %w", err)
+		return fmt.Errorf("error performing http request: %w", err)
 	}
 	defer resp.Body.Close()
 	gResp, err := fromReader[Response](c.key, resp.Body)
+	if gResp != nil && gResp.Error != nil {
+		return errors.New(gResp.Error.Message)
+	}
 	if err != nil {
-		if gResp != nil && gResp.Error != nil {
-			return nil, errors.New(gResp.Error.Message)
-		}
-		return nil, fmt.Errorf("error decoding http response: %w", err)
+		return fmt.Errorf("error decoding http response: %w", err)
+	}
+	if err := json.Unmarshal(gResp.Result, result); err != nil {
+		return fmt.Errorf("error decoding jsonrpc result: %w", err)
 	}
-	return Result[LoggersResult](gResp)
+	return nil
 }
 
 func (c *Client) getID() int {
@@ -56,6 +56,16 @@ func (s *Server) ServiceHandler(w http.ResponseWriter, req *http.Request) {
 			return
 		}
 		return
+	case "registered":
+		result := Registered()
+		resp, err := NewResponse[RegisteredResult](r.ID, &result)
+		if err != nil {
+			s.JSONRPCError(w, r.ID, 3, err)
+			return
+		}
+		if err := toWriter(s.key, w, resp); err != nil {
+			s.HTTPError(w, err)
+		}
 	case "apply":
 		applyParams, err := Params[ApplyParams](r)
 		if err != nil {
@@ -75,6 +85,25 @@ func (s *Server) ServiceHandler(w http.ResponseWriter, req *http.Request) {
 		if err := toWriter(s.key, w, resp); err != nil {
 			s.HTTPError(w, err)
 		}
+	case "recent":
+		recentParams, err := Params[RecentParams](r)
+		if err != nil {
+			s.JSONRPCError(w, r.ID, 3, err)
+			return
+		}
+		result, err := Recent(recentParams)
+		if err != nil {
+			s.JSONRPCError(w, r.ID, 3, err)
+			return
+		}
+		resp, err := NewResponse[RecentResult](r.ID, &result)
+		if err != nil {
+			s.JSONRPCError(w, r.ID, 3, err)
+			return
+		}
+		if err := toWriter(s.key, w, resp); err != nil {
+			s.HTTPError(w, err)
+		}
 
 	default:
 		// -32601 is from jsonrpc 2.0
@@ -3,3 +3,18 @@ package rpc
 import "github.com/scott-cotton/L"
 
 type LoggersResult []L.ConfigNode
+
+// RegisteredResult gives the names of the registered fmters, writers and
+// middleware which may be used in the Specs of an apply.
+type RegisteredResult struct {
+	Fmters      []string `json:"fmters"`
+	Writers     []string `json:"writers"`
+	Middlewares []string `json:"middlewares"`
+}
+
+// Registered returns the names registered with L.
+func Registered() RegisteredResult {
+	var res RegisteredResult
+	res.Fmters, res.Writers, res.Middlewares = L.Registered()
+	return res
+}
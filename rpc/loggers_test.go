@@ -0,0 +1,34 @@
+package rpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+)
+
+func TestRegistered(t *testing.T) {
+	s := NewServer("abc", "", "/")
+	hs := httptest.NewServer(http.HandlerFunc(s.ServiceHandler))
+	defer hs.Close()
+	client, err := NewClient("abc", hs.URL+"/L")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := client.Registered()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		names []string
+		name  string
+	}{
+		{res.Fmters, "json"},
+		{res.Writers, "stderr"},
+		{res.Middlewares, "pkg"},
+	} {
+		if i := sort.SearchStrings(c.names, c.name); i == len(c.names) || c.names[i] != c.name {
+			t.Errorf("%q not in %v", c.name, c.names)
+		}
+	}
+}
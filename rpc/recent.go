@@ -0,0 +1,20 @@
+package rpc
+
+import "github.com/scott-cotton/L"
+
+type RecentParams = L.RingQuery
+
+type RecentResult []L.RingRecord
+
+// Recent queries the RingSinks of all loggers.
+func Recent(parms *RecentParams) (RecentResult, error) {
+	var res RecentResult
+	for _, r := range L.RingSinks() {
+		recs, err := r.Query(parms)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, recs...)
+	}
+	return RecentResult(L.LimitRecords(res, parms.Limit)), nil
+}
@@ -0,0 +1,41 @@
+package rpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/scott-cotton/L"
+)
+
+func TestRecent(t *testing.T) {
+	ring := L.NewRingSink(10, 0)
+	cfg := L.NewConfig(".recent")
+	cfg.Sinks = []L.Sink{ring.Sink("ring")}
+	l := L.New(cfg)
+	defer l.Close()
+	l.Dict().Field("msg", "a").Log()
+	l.Dict().Field("msg", "b").Log()
+
+	s := NewServer("abc", "", "/")
+	hs := httptest.NewServer(http.HandlerFunc(s.ServiceHandler))
+	defer hs.Close()
+	client, err := NewClient("abc", hs.URL+"/L")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := client.Recent(&RecentParams{
+		PkgPattern: "^github.com/scott-cotton/L/rpc$",
+		Labels:     []string{".recent"},
+		Limit:      1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*res) != 1 || string((*res)[0].Record) != `{"msg":"b"}` {
+		t.Errorf("got %+v", *res)
+	}
+	if _, err := client.Recent(&RecentParams{PkgPattern: "("}); err == nil {
+		t.Errorf("no error for invalid pattern")
+	}
+}
@@ -0,0 +1,324 @@
+package L
+
+import (
+	"fmt"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Kind is the kind of a Token.
+type Kind byte
+
+const (
+	// KindEnd is the kind of the token following the last one, or
+	// following malformed input.
+	KindEnd Kind = iota
+	KindDict
+	KindDictEnd
+	KindArray
+	KindArrayEnd
+	// KindKey is the kind of the keys of dicts.  Other strings
+	// have KindString.
+	KindKey
+	KindString
+	KindNumber
+	KindBool
+	KindNull
+)
+
+// Token is a token of json scanned by a Scanner.
+type Token struct {
+	Kind Kind
+	// Start and End are the offsets of the token in the input.  For
+	// keys and strings, they include the quotes.
+	Start, End int
+	// Depth is the number of dicts and arrays enclosing the token.
+	// The start and end tokens of a dict or array have the depth of
+	// the dict or array.
+	Depth int
+}
+
+// Scanner splits json, such as that given to Fmter.Fmt, into Tokens
+// without allocating.  Separators are skipped.  A Scanner does not fully
+// validate its input, but stops with an error at bytes which cannot
+// start a token.
+//
+// TableFmter, LogfmtFmter, ConsoleFmter, SyslogFmter, GELFFmter,
+// ECSFmter and OTLPFmter read records with a Scanner and write them
+// without allocating.
+//
+// The zero Scanner is ready to use after a call to Reset.
+type Scanner struct {
+	d     []byte
+	i     int
+	depth int
+	key   bool
+	// dicts holds a bit for each of the first 64 levels of nesting,
+	// set for dicts, and more holds the deeper levels.
+	dicts uint64
+	more  []bool
+	err   error
+}
+
+// Reset resets 's' to scan 'd'.
+func (s *Scanner) Reset(d []byte) {
+	more := s.more[:0]
+	*s = Scanner{d: d, more: more}
+}
+
+// Err returns the error which stopped 's', if any.
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// Depth returns the number of dicts and arrays enclosing the next
+// token.
+func (s *Scanner) Depth() int {
+	return s.depth
+}
+
+func (s *Scanner) push(dict bool) {
+	if s.depth < 64 {
+		if dict {
+			s.dicts |= 1 << s.depth
+		} else {
+			s.dicts &^= 1 << s.depth
+		}
+	} else {
+		s.more = append(s.more, dict)
+	}
+	s.depth++
+	s.key = dict
+}
+
+func (s *Scanner) pop() {
+	if s.depth == 0 {
+		return
+	}
+	s.depth--
+	if s.depth >= 64 {
+		s.more = s.more[:len(s.more)-1]
+	}
+	s.key = false
+}
+
+// inDict returns whether the next token is in a dict.
+func (s *Scanner) inDict() bool {
+	i := s.depth - 1
+	switch {
+	case i < 0:
+		return false
+	case i < 64:
+		return s.dicts&(1<<i) != 0
+	}
+	return s.more[i-64]
+}
+
+// Next returns the next token.
+func (s *Scanner) Next() Token {
+	d := s.d
+	i := s.i
+	for ; i < len(d); i++ {
+		c := d[i]
+		if c == ',' {
+			s.key = s.inDict()
+			continue
+		}
+		if c != ':' && !isSpace(c) {
+			break
+		}
+	}
+	if i >= len(d) || s.err != nil {
+		s.i = len(d)
+		return Token{Start: len(d), End: len(d)}
+	}
+	tok := Token{Start: i, End: i + 1, Depth: s.depth}
+	switch c := d[i]; c {
+	case '{':
+		tok.Kind = KindDict
+		s.push(true)
+	case '[':
+		tok.Kind = KindArray
+		s.push(false)
+	case '}', ']':
+		tok.Kind = KindDictEnd
+		if c == ']' {
+			tok.Kind = KindArrayEnd
+		}
+		s.pop()
+		tok.Depth = s.depth
+	case '"':
+		tok.End = skipString(d, i)
+		tok.Kind = KindString
+		if s.key {
+			tok.Kind = KindKey
+			s.key = false
+		}
+	default:
+		tok.End = skipValue(d, i)
+		switch {
+		case c == 't' || c == 'f':
+			tok.Kind = KindBool
+		case c == 'n':
+			tok.Kind = KindNull
+		case c == '-' || c >= '0' && c <= '9':
+			tok.Kind = KindNumber
+		default:
+			s.err = fmt.Errorf("invalid character %q at offset %d", c, i)
+			s.i = len(d)
+			return Token{Start: i, End: i}
+		}
+	}
+	s.i = tok.End
+	return tok
+}
+
+// Skip skips the value following the key 'tok', or the rest of the dict
+// or array started by 'tok', returning the offset of the end of the
+// value.  For other tokens, Skip returns tok.End.
+func (s *Scanner) Skip(tok Token) int {
+	switch tok.Kind {
+	case KindKey:
+		v := s.Next()
+		if v.Kind == KindDict || v.Kind == KindArray {
+			return s.Skip(v)
+		}
+		return v.End
+	case KindDict, KindArray:
+		if s.depth <= tok.Depth {
+			return tok.End
+		}
+		s.i = skipValue(s.d, tok.Start)
+		for s.depth > tok.Depth {
+			s.pop()
+		}
+		return s.i
+	}
+	return tok.End
+}
+
+// resetAt resets 's' to scan the dict or array starting at d[i], and
+// reads its start token.
+func (s *Scanner) resetAt(d []byte, i int) {
+	s.Reset(d)
+	s.i = i
+	s.Next()
+}
+
+// field returns the next field of the dict being scanned, skipping its
+// value, or false at the end of the dict.
+func (s *Scanner) field() (member, bool) {
+	k := s.Next()
+	if k.Kind != KindKey {
+		return member{}, false
+	}
+	v := s.Next()
+	switch v.Kind {
+	case KindEnd, KindDictEnd, KindArrayEnd, KindKey:
+		return member{}, false
+	}
+	return member{ks: k.Start, ke: k.End, vs: v.Start, ve: s.Skip(v)}, true
+}
+
+// elem returns the next element of the array being scanned, skipping
+// it, or false at the end of the array.
+func (s *Scanner) elem() (member, bool) {
+	v := s.Next()
+	switch v.Kind {
+	case KindEnd, KindDictEnd, KindArrayEnd, KindKey:
+		return member{}, false
+	}
+	return member{vs: v.Start, ve: s.Skip(v)}, true
+}
+
+// AppendUnquote appends the string encoded by the json string 'raw',
+// including its quotes, to 'dst'.  Invalid escapes are copied as they
+// are, and invalid UTF-8 and surrogates are replaced by U+FFFD, as in
+// encoding/json.
+func AppendUnquote(dst, raw []byte) []byte {
+	if len(raw) < 2 {
+		return dst
+	}
+	s := raw[1 : len(raw)-1]
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= utf8.RuneSelf {
+			r, size := utf8.DecodeRune(s[i:])
+			if r == utf8.RuneError && size == 1 {
+				dst = append(dst, "\ufffd"...)
+			} else {
+				dst = append(dst, s[i:i+size]...)
+			}
+			i += size
+			continue
+		}
+		if c != '\\' || i+1 == len(s) {
+			j := i + 1
+			for j < len(s) && s[j] != '\\' && s[j] < utf8.RuneSelf {
+				j++
+			}
+			dst = append(dst, s[i:j]...)
+			i = j
+			continue
+		}
+		switch e := s[i+1]; e {
+		case '"', '\\', '/':
+			dst = append(dst, e)
+		case 'b':
+			dst = append(dst, '\b')
+		case 'f':
+			dst = append(dst, '\f')
+		case 'n':
+			dst = append(dst, '\n')
+		case 'r':
+			dst = append(dst, '\r')
+		case 't':
+			dst = append(dst, '\t')
+		case 'u':
+			r, ok := hex4(s[i+2:])
+			if !ok {
+				dst = append(dst, s[i:i+2]...)
+				break
+			}
+			i += 4
+			if utf16.IsSurrogate(r) {
+				r2, ok := rune(-1), false
+				if len(s) >= i+8 && s[i+2] == '\\' && s[i+3] == 'u' {
+					r2, ok = hex4(s[i+4:])
+				}
+				if dec := utf16.DecodeRune(r, r2); ok && dec != utf8.RuneError {
+					r = dec
+					i += 6
+				} else {
+					r = utf8.RuneError
+				}
+			}
+			dst = utf8.AppendRune(dst, r)
+		default:
+			dst = append(dst, s[i:i+2]...)
+		}
+		i += 2
+	}
+	return dst
+}
+
+func hex4(s []byte) (rune, bool) {
+	if len(s) < 4 {
+		return 0, false
+	}
+	var r rune
+	for _, c := range s[:4] {
+		switch {
+		case c >= '0' && c <= '9':
+			c -= '0'
+		case c >= 'a' && c <= 'f':
+			c -= 'a' - 10
+		case c >= 'A' && c <= 'F':
+			c -= 'A' - 10
+		default:
+			return 0, false
+		}
+		r = r<<4 | rune(c)
+	}
+	return r, true
+}
@@ -0,0 +1,102 @@
+package L
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestScanner(t *testing.T) {
+	d := []byte(` {"a": [1, "x", {"b":null}], "c" : true, "d":{}} `)
+	want := []struct {
+		kind  Kind
+		raw   string
+		depth int
+	}{
+		{KindDict, `{`, 0},
+		{KindKey, `"a"`, 1},
+		{KindArray, `[`, 1},
+		{KindNumber, `1`, 2},
+		{KindString, `"x"`, 2},
+		{KindDict, `{`, 2},
+		{KindKey, `"b"`, 3},
+		{KindNull, `null`, 3},
+		{KindDictEnd, `}`, 2},
+		{KindArrayEnd, `]`, 1},
+		{KindKey, `"c"`, 1},
+		{KindBool, `true`, 1},
+		{KindKey, `"d"`, 1},
+		{KindDict, `{`, 1},
+		{KindDictEnd, `}`, 1},
+		{KindDictEnd, `}`, 0},
+		{KindEnd, ``, 0},
+	}
+	var s Scanner
+	s.Reset(d)
+	for i, w := range want {
+		tok := s.Next()
+		if tok.Kind != w.kind || string(d[tok.Start:tok.End]) != w.raw || tok.Depth != w.depth {
+			t.Errorf("%d: got %v %q %d want %v", i, tok.Kind, d[tok.Start:tok.End], tok.Depth, w)
+		}
+	}
+	if s.Err() != nil {
+		t.Error(s.Err())
+	}
+
+	// skipping
+	s.Reset(d)
+	s.Next()
+	k := s.Next()
+	if end := s.Skip(k); string(d[k.End:end]) != `: [1, "x", {"b":null}]` {
+		t.Errorf("Skip: %q", d[k.End:end])
+	}
+	if tok := s.Next(); string(d[tok.Start:tok.End]) != `"c"` || tok.Depth != 1 {
+		t.Errorf("after Skip: %v", tok)
+	}
+
+	s.Reset([]byte(`[1,x]`))
+	for s.Next().Kind != KindEnd {
+	}
+	if s.Err() == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestScannerFields(t *testing.T) {
+	d := []byte(`x {"a": [1, {"b":2}], "c": {"d": []}, "e": "f"`)
+	var s Scanner
+	s.resetAt(d, 2)
+	var got []string
+	for m, ok := s.field(); ok; m, ok = s.field() {
+		got = append(got, string(d[m.ks:m.ke])+"="+string(d[m.vs:m.ve]))
+	}
+	if exp := `["a"=[1, {"b":2}] "c"={"d": []} "e"="f"]`; fmt.Sprint(got) != exp {
+		t.Errorf("fields: got %s exp %s", got, exp)
+	}
+	s.resetAt(d, 8)
+	got = got[:0]
+	for m, ok := s.elem(); ok; m, ok = s.elem() {
+		got = append(got, string(d[m.vs:m.ve]))
+	}
+	if exp := `[1 {"b":2}]`; fmt.Sprint(got) != exp {
+		t.Errorf("elems: got %s exp %s", got, exp)
+	}
+}
+
+func FuzzAppendUnquote(f *testing.F) {
+	for _, s := range []string{`""`, `"a\"b"`, `"é\n\t\/"`, `"😀"`, `"\ud83d"`, `"\ud83dx"`, `"\udc00\ud800"`, "\"\xd1\""} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		var exp string
+		if err := json.Unmarshal([]byte(s), &exp); err != nil {
+			return
+		}
+		if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+			return
+		}
+		if got := string(AppendUnquote(nil, []byte(s))); got != exp {
+			t.Fatalf("%s: got %q exp %q", s, got, exp)
+		}
+	})
+}
@@ -0,0 +1,173 @@
+package L
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// schemaKeys are the keys of the fields of records which are mapped to
+// dedicated fields by GELFFmter and ECSFmter, with defaults "time",
+// time.RFC3339Nano and "msg".
+type schemaKeys struct {
+	timeKey    string
+	timeLayout string
+	msgKey     string
+	levelKey   string
+}
+
+// schemaRecord is a record split into the fields mapped by GELFFmter
+// and ECSFmter, as raw json, and the rest.
+type schemaRecord struct {
+	time    time.Time
+	msg     []byte
+	level   int
+	leveled bool
+	err     []byte // "Lerr", as written by Obj.Err
+	pkg     []byte // "Lpkg", as written by the Pkg middleware
+	caller  []byte // "Lcaller", as written by Obj.Caller
+	stack   []byte // "Lstack", as written by Obj.Stack
+	rest    []member
+}
+
+// schemaState holds the buffers of a call to the Fmt method of
+// GELFFmter, ECSFmter or OTLPFmter.
+type schemaState struct {
+	sc   Scanner
+	r    schemaRecord
+	buf  []byte
+	tmp  []byte
+	text []byte // the text of stack traces
+	key  []byte // the dotted key of a GELF additional field
+	// keys, vals and nodes hold the document built by ECSFmter.
+	keys  []byte
+	vals  []byte
+	nodes []ecsNode
+	// attrs and names hold the attributes and resource keys of
+	// OTLPFmter.
+	attrs []member
+	names []string
+}
+
+var schemaPool = sync.Pool{
+	New: func() any { return &schemaState{} },
+}
+
+// split splits 'd' into st.r.
+func (st *schemaState) split(d []byte, keys schemaKeys) *schemaRecord {
+	r := &st.r
+	*r = schemaRecord{rest: r.rest[:0]}
+	var (
+		timeKey = orDefault(keys.timeKey, "time")
+		layout  = orDefault(keys.timeLayout, time.RFC3339Nano)
+		msgKey  = orDefault(keys.msgKey, "msg")
+	)
+	i := skipSpace(d, 0)
+	if i == len(d) || d[i] != '{' {
+		r.msg = d[i:]
+		return r
+	}
+	sc := &st.sc
+	sc.resetAt(d, i)
+	for m, ok := sc.field(); ok; m, ok = sc.field() {
+		if !st.take(d[m.ks:m.ke], d[m.vs:m.ve], timeKey, layout, msgKey, keys.levelKey) {
+			r.rest = append(r.rest, m)
+		}
+	}
+	return r
+}
+
+// take sets the mapped field of st.r with key 'k' to 'v', returning
+// false if there is none or it is already set.
+func (st *schemaState) take(k, v []byte, timeKey, layout, msgKey, levelKey string) bool {
+	r := &st.r
+	switch {
+	case keyIs(k, timeKey) && r.time.IsZero() && v[0] == '"':
+		st.tmp = AppendUnquote(st.tmp[:0], v)
+		if t, err := time.Parse(layout, string(st.tmp)); err == nil {
+			r.time = t
+			return true
+		}
+	case keyIs(k, msgKey) && r.msg == nil:
+		r.msg = v
+		return true
+	case levelKey != "" && keyIs(k, levelKey) && !r.leveled:
+		if n, err := strconv.Atoi(string(v)); err == nil {
+			r.level, r.leveled = n, true
+			return true
+		}
+	case keyIs(k, "Lerr") && r.err == nil && v[0] == '{':
+		r.err = v
+		return true
+	case keyIs(k, "Lpkg") && r.pkg == nil && v[0] == '"':
+		r.pkg = v
+		return true
+	case keyIs(k, "Lcaller") && r.caller == nil && v[0] == '{':
+		r.caller = v
+		return true
+	case keyIs(k, "Lstack") && r.stack == nil && v[0] == '[':
+		r.stack = v
+		return true
+	}
+	return false
+}
+
+// appendTextString appends the text of the json value 'v' as a json
+// string.
+func (st *schemaState) appendTextString(dst, v []byte) []byte {
+	st.tmp = appendValueText(st.tmp[:0], v)
+	return appendString(dst, st.tmp, false)
+}
+
+// appendValueText appends the text of the json value 'v': the string if
+// it is a string and otherwise the json.
+func appendValueText(dst, v []byte) []byte {
+	if len(v) > 0 && v[0] == '"' {
+		return AppendUnquote(dst, v)
+	}
+	return append(dst, v...)
+}
+
+// appendStackTrace appends the frames of 'stack' as written by Obj.Stack,
+// formatted in the manner of runtime/debug.Stack, as a json string.
+func (st *schemaState) appendStackTrace(dst, stack []byte) []byte {
+	b := st.text[:0]
+	var sc Scanner
+	sc.resetAt(stack, 0)
+	for m, ok := sc.elem(); ok; m, ok = sc.elem() {
+		file, line, fn := callerFields(stack[m.vs:m.ve])
+		b = appendValueText(b, fn)
+		b = append(b, "\n\t"...)
+		b = appendValueText(b, file)
+		b = append(b, ':')
+		b = append(b, line...)
+		b = append(b, '\n')
+	}
+	st.text = b
+	return appendString(dst, b, false)
+}
+
+// callerFields returns the first fields "file", "line" and "func" of the
+// dict 'c', as written by Obj.Caller and Obj.Stack.
+func callerFields(c []byte) (file, line, fn []byte) {
+	var sc Scanner
+	sc.resetAt(c, 0)
+	for m, ok := sc.field(); ok; m, ok = sc.field() {
+		k, v := c[m.ks:m.ke], c[m.vs:m.ve]
+		switch {
+		case file == nil && keyIs(k, "file"):
+			file = v
+		case line == nil && keyIs(k, "line"):
+			line = v
+		case fn == nil && keyIs(k, "func"):
+			fn = v
+		}
+	}
+	return file, line, fn
+}
+
+var hostname = func() string {
+	h, _ := os.Hostname()
+	return h
+}()
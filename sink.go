@@ -0,0 +1,187 @@
+package L
+
+import (
+	"fmt"
+	"io"
+)
+
+// Sink is a destination for the objects logged by a logger in addition
+// to Config.W, with its own Fmter, Post middlewares and error handler.
+//
+// The Post middlewares of a Sink run after those of the Config, on a copy
+// of the object, so they affect only the Sink.  If a Sink fails to format
+// or write an object, its E is called with a *SinkError, or if its E is
+// nil, the E of the Config.  Error handlers are called after the object
+// has been given to all Sinks, so that a failing Sink does not stop the
+// others.
+type Sink struct {
+	// Name identifies the Sink, for example in Config.Apply.
+	Name string `json:"name"`
+	// W and F are the writer and Fmter of the Sink.  A Sink without
+	// either is skipped.
+	W io.Writer `json:"-"`
+	F Fmter     `json:"-"`
+	// Post are Middlewares applied for the Sink only.
+	Post []Middleware `json:"-"`
+	// E handles the errors of the Sink.
+	E func(*Config, error) `json:"-"`
+
+	// WSpec, FSpec and PostSpec describe W, F and Post as in
+	// Config.
+	WSpec    *Spec  `json:"w,omitempty"`
+	FSpec    *Spec  `json:"f,omitempty"`
+	PostSpec []Spec `json:"post,omitempty"`
+}
+
+// SinkError is the error of a logger failing to format or write an
+// object, or a change of state of a writer which is a StatusReporter,
+// given to the E of its Config or Sink.
+type SinkError struct {
+	// Package is the package of the logger.
+	Package string
+	// Sink is the name of the Sink, or empty for the writer of the
+	// Config.
+	Sink string
+	Err  error
+}
+
+func (e *SinkError) Error() string {
+	if e.Sink == "" {
+		return fmt.Sprintf("%s: %v", e.Package, e.Err)
+	}
+	return fmt.Sprintf("%s: sink %q: %v", e.Package, e.Sink, e.Err)
+}
+
+func (e *SinkError) Unwrap() error {
+	return e.Err
+}
+
+func cloneSinks(ss []Sink) []Sink {
+	if ss == nil {
+		return nil
+	}
+	res := make([]Sink, len(ss))
+	for i, s := range ss {
+		res[i] = s
+		res[i].Post = append([]Middleware(nil), s.Post...)
+		res[i].PostSpec = append([]Spec(nil), s.PostSpec...)
+	}
+	return res
+}
+
+// applySinks returns the Sinks 'o' applied to the Sinks 'c'.  A Sink in
+// 'o' without a W, F, Post or E takes it from the Sink in 'c' with the
+// same name, so that Sinks decoded from json, which have names only, may
+// reorder or remove the Sinks of 'c'.
+func applySinks(c, o []Sink) []Sink {
+	res := cloneSinks(o)
+	for i := range res {
+		s := &res[i]
+		for j := range c {
+			if c[j].Name != s.Name {
+				continue
+			}
+			if s.W == nil {
+				s.W, s.WSpec = c[j].W, c[j].WSpec
+			}
+			if s.F == nil {
+				s.F, s.FSpec = c[j].F, c[j].FSpec
+			}
+			if s.Post == nil {
+				s.Post = append([]Middleware(nil), c[j].Post...)
+				s.PostSpec = append([]Spec(nil), c[j].PostSpec...)
+			}
+			if s.E == nil {
+				s.E = c[j].E
+			}
+			break
+		}
+	}
+	return res
+}
+
+func (s *Sink) resolve() error {
+	var err error
+	if s.PostSpec != nil {
+		if s.Post, err = newMiddlewares(s.PostSpec); err != nil {
+			return err
+		}
+	}
+	if s.WSpec != nil {
+		if s.W, err = NewWriter(s.WSpec); err != nil {
+			return err
+		}
+	}
+	if s.FSpec != nil {
+		if s.F, err = NewFmter(s.FSpec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sinkObjs returns the objects to give to the Sinks of 'cfg' for the open
+// object 'o': 'o' itself for Sinks without Post middlewares, and
+// otherwise a copy to which they have been applied, or nil if they
+// filtered it out.
+func sinkObjs(cfg *Config, o *Obj) []*Obj {
+	if len(cfg.Sinks) == 0 {
+		return nil
+	}
+	res := make([]*Obj, len(cfg.Sinks))
+	for i := range cfg.Sinks {
+		s := &cfg.Sinks[i]
+		if len(s.Post) == 0 {
+			res[i] = o
+			continue
+		}
+		c := o.Clone()
+		for _, mw := range s.Post {
+			c = mw(cfg, c)
+		}
+		c.runLazy()
+		res[i] = c
+	}
+	return res
+}
+
+// writeSinks formats the objects 'objs' for the Sinks of 'cfg', and then
+// calls the error handlers of those which failed, and of those whose
+// writers report changes of state.
+func writeSinks(cfg *Config, objs []*Obj) {
+	var errs []int
+	var errv []error
+	for i, c := range objs {
+		s := &cfg.Sinks[i]
+		if c == nil || s.W == nil || s.F == nil {
+			continue
+		}
+		if err := writeSink(cfg, s, c); err != nil {
+			errs = append(errs, i)
+			errv = append(errv, &SinkError{Package: cfg.pkg, Sink: s.Name, Err: err})
+		}
+	}
+	for j, i := range errs {
+		if e := cfg.Sinks[i].errorHandler(cfg); e != nil {
+			e(cfg, errv[j])
+		}
+	}
+	for i := range cfg.Sinks {
+		s := &cfg.Sinks[i]
+		reportStatus(cfg, s.Name, s.W, s.errorHandler(cfg))
+	}
+}
+
+func (s *Sink) errorHandler(cfg *Config) func(*Config, error) {
+	if s.E != nil {
+		return s.E
+	}
+	return cfg.E
+}
+
+func writeSink(cfg *Config, s *Sink, o *Obj) error {
+	if err := o.Close(); err != nil {
+		return err
+	}
+	return fmtSafe(cfg, s.F, s.W, o.D())
+}
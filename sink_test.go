@@ -0,0 +1,124 @@
+package L_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/scott-cotton/L"
+)
+
+type panicFmter struct{}
+
+func (panicFmter) Fmt(io.Writer, []byte) error {
+	panic("boom")
+}
+
+type failWriter struct{}
+
+func (failWriter) Write([]byte) (int, error) {
+	return 0, errors.New("fail")
+}
+
+func TestSinks(t *testing.T) {
+	main := bytes.NewBuffer(nil)
+	a := bytes.NewBuffer(nil)
+	b := bytes.NewBuffer(nil)
+	var errs []error
+	var mainErrs []error
+	l := L.New(&L.Config{
+		Labels: map[string]int{"on": 1},
+		W:      main,
+		F:      L.JSONFmter(),
+		E:      func(_ *L.Config, err error) { mainErrs = append(mainErrs, err) },
+		Sinks: []L.Sink{
+			{Name: "fail", W: failWriter{}, F: L.JSONFmter(),
+				E: func(_ *L.Config, err error) { errs = append(errs, err) }},
+			{Name: "a", W: a, F: L.JSONFmter(),
+				Post: []L.Middleware{func(_ *L.Config, o *L.Obj) *L.Obj {
+					return o.Field("sink", "a")
+				}}},
+			{Name: "b", W: b, F: &L.LogfmtFmter{},
+				Post: []L.Middleware{L.If("b")}},
+			{Name: "panic", W: b, F: panicFmter{}},
+		},
+	})
+	l.Dict().Field("x", 1).LazyField("y", func() any { return 2 }).Log()
+	if got, want := main.String(), `{"x":1,"y":2}`+"\n"; got != want {
+		t.Errorf("main: got %q want %q", got, want)
+	}
+	if got, want := a.String(), `{"x":1,"y":2,"sink":"a"}`+"\n"; got != want {
+		t.Errorf("a: got %q want %q", got, want)
+	}
+	if b.Len() != 0 {
+		t.Errorf("b: got %q", b.String())
+	}
+	var se *L.SinkError
+	if len(errs) != 1 || !errors.As(errs[0], &se) || se.Sink != "fail" {
+		t.Errorf("sink errors: %v", errs)
+	}
+	if len(mainErrs) != 1 || !errors.As(mainErrs[0], &se) || se.Sink != "panic" {
+		t.Errorf("main errors: %v", mainErrs)
+	}
+
+	// apply from json keeps the writers of named sinks.
+	var mod L.Config
+	if err := json.Unmarshal([]byte(`{"sinks":[{"name":"b","extra":1},{"name":"a"}]}`), &mod); err != nil {
+		t.Fatal(err)
+	}
+	l.ApplyConfig(&mod, nil)
+	cfg := l.ReadConfig()
+	if len(cfg.Sinks) != 2 || cfg.Sinks[0].W != b || cfg.Sinks[1].W != a || len(cfg.Sinks[1].Post) != 1 {
+		t.Fatalf("applied sinks: %+v", cfg.Sinks)
+	}
+	a.Reset()
+	l.With("b", 1).Dict().Field("x", 3).Log()
+	if got, want := b.String(), "x=3\n"; got != want {
+		t.Errorf("b: got %q want %q", got, want)
+	}
+	if got, want := a.String(), `{"x":3,"sink":"a"}`+"\n"; got != want {
+		t.Errorf("a: got %q want %q", got, want)
+	}
+
+	// sinks appear in the config tree by name.
+	d, err := json.Marshal(l.ConfigTree(nil)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(d, []byte(`"sinks":[{"name":"b"},{"name":"a"}]`)) {
+		t.Errorf("config tree: %s", d)
+	}
+}
+
+func TestEDefault(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	stderr := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = stderr }()
+
+	cfg := L.NewConfig()
+	cfg.W, cfg.F = failWriter{}, L.JSONFmter()
+	l := L.New(cfg)
+	defer l.Close()
+	// errors in writing are reported without panicking.
+	l.Dict().Field("a", 1).Log()
+	w.Close()
+	d, _ := io.ReadAll(r)
+	if got, want := string(d), `{"LE":"github.com/scott-cotton/L_test: fail"}`+"\n"; got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+	// errors in constructing objects panic.
+	defer func() {
+		if recover() == nil {
+			t.Errorf("no panic logging invalid json")
+		}
+	}()
+	l.Str("a").Str("b").Log()
+}
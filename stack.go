@@ -0,0 +1,178 @@
+package L
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Caller adds a field "Lcaller" to 't', which should be a dict, giving the
+// location of the call to Caller, or that of its 'skip'th caller.  The
+// value is a dict of the form
+//
+//	{"file": "/path/to/file.go", "line": 17, "func": "pkg.Func"}
+//
+// Only the program counter is captured immediately; it is resolved to a
+// location, at most once per program counter, when 't' is logged.
+func (t *Obj) Caller(skip int) *Obj {
+	if t == nil {
+		return nil
+	}
+	var pc [1]uintptr
+	if runtime.Callers(skip+2, pc[:]) == 0 {
+		return t
+	}
+	return t.LazyFn(func(o *Obj) {
+		encCaller(o, pc[:], false)
+	})
+}
+
+// Stack adds a field "Lstack" to 't', which should be a dict, giving the
+// stack of the current goroutine from the caller of Stack, as an array of
+// dicts of the form given by Caller.  As with Caller, the stack is
+// resolved when 't' is logged.
+func (t *Obj) Stack() *Obj {
+	if t == nil {
+		return nil
+	}
+	pcs := callers(1)
+	return t.LazyFn(func(o *Obj) {
+		encStack(o, pcs, false)
+	})
+}
+
+// Caller returns a Middleware which adds a field "Lcaller" to dicts as
+// Obj.Caller does, giving the first caller outside of package L.  Caller
+// should be used as a Post middleware, in which case the caller is
+// ordinarily the one which logged the dict.
+func Caller() Middleware {
+	return func(_ *Config, o *Obj) *Obj {
+		if o == nil {
+			return nil
+		}
+		if _, _, ok := o.lazyAt(); !ok {
+			return o
+		}
+		pcs := make([]uintptr, 16)
+		pcs = pcs[:runtime.Callers(2, pcs)]
+		return o.LazyFn(func(c *Obj) {
+			encCaller(c, pcs, true)
+		})
+	}
+}
+
+// StackOnErr returns a Middleware which adds a field "Lstack" to dicts
+// with a non-null "Lerr" field, as set by Obj.Err, as Obj.Stack does.
+// The stack starts with the first caller outside of package L.
+// StackOnErr should be used as a Post middleware.
+func StackOnErr() Middleware {
+	return func(_ *Config, o *Obj) *Obj {
+		if v, ok := o.Get("Lerr"); !ok || string(v) == "null" {
+			return o
+		}
+		pcs := callers(1)
+		return o.LazyFn(func(c *Obj) {
+			encStack(c, pcs, true)
+		})
+	}
+}
+
+// callers returns the program counters of the stack, starting 'skip'
+// frames above the caller of callers.
+func callers(skip int) []uintptr {
+	pcs := make([]uintptr, 32)
+	for {
+		n := runtime.Callers(skip+2, pcs)
+		if n < len(pcs) || len(pcs) >= maxDepth {
+			return pcs[:n]
+		}
+		pcs = make([]uintptr, 2*len(pcs))
+	}
+}
+
+// frame is a resolved location.
+type frame struct {
+	file string
+	line int
+	fn   string
+	// internal is true for the non-test source files of package L.
+	internal bool
+}
+
+// frameCache maps program counters to their frames, of
+// which there may be several due to inlining.
+var frameCache sync.Map
+
+// pcFrames returns the frames of the program counter 'pc', as
+// returned by runtime.Callers.
+func pcFrames(pc uintptr) []frame {
+	if v, ok := frameCache.Load(pc); ok {
+		return v.([]frame)
+	}
+	var res []frame
+	fs := runtime.CallersFrames([]uintptr{pc})
+	for {
+		f, more := fs.Next()
+		res = append(res, frame{
+			file:     f.File,
+			line:     f.Line,
+			fn:       f.Function,
+			internal: isInternal(f.File),
+		})
+		if !more {
+			break
+		}
+	}
+	frameCache.Store(pc, res)
+	return res
+}
+
+var srcDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+func isInternal(file string) bool {
+	return filepath.Dir(file) == srcDir && !strings.HasSuffix(file, "_test.go")
+}
+
+// rangeFrames calls 'fn' with the frames of 'pcs' until 'fn' returns
+// false, skipping leading frames in package L if 'external' is true.
+func rangeFrames(pcs []uintptr, external bool, fn func(*frame) bool) {
+	for _, pc := range pcs {
+		fs := pcFrames(pc)
+		for i := range fs {
+			f := &fs[i]
+			if external && f.internal {
+				continue
+			}
+			external = false
+			if !fn(f) {
+				return
+			}
+		}
+	}
+}
+
+func encCaller(o *Obj, pcs []uintptr, external bool) {
+	rangeFrames(pcs, external, func(f *frame) bool {
+		encFrame(o.key("Lcaller"), f)
+		return false
+	})
+}
+
+func encStack(o *Obj, pcs []uintptr, external bool) {
+	a := o.key("Lstack").Array()
+	rangeFrames(pcs, external, func(f *frame) bool {
+		encFrame(a, f)
+		return true
+	})
+	a.Close()
+}
+
+func encFrame(o *Obj, f *frame) {
+	d := o.Dict()
+	d.Field("file", f.file).Field("line", f.line).Field("func", f.fn)
+	d.Close()
+}
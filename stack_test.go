@@ -0,0 +1,101 @@
+package L_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/scott-cotton/L"
+)
+
+type stackFrame struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+	Func string `json:"func"`
+}
+
+type stackLine struct {
+	Lcaller *stackFrame  `json:"Lcaller"`
+	Lstack  []stackFrame `json:"Lstack"`
+}
+
+func here() (string, int) {
+	_, file, line, _ := runtime.Caller(1)
+	return file, line
+}
+
+func TestStack(t *testing.T) {
+	w := bytes.NewBuffer(nil)
+	cfg := &L.Config{
+		Labels: map[string]int{},
+		W:      w,
+		F:      L.JSONFmter(),
+		E:      L.EPanic,
+		Post:   []L.Middleware{L.Caller(), L.StackOnErr()},
+	}
+	l := L.New(cfg)
+	decode := func() stackLine {
+		t.Helper()
+		var res stackLine
+		if err := json.Unmarshal(w.Bytes(), &res); err != nil {
+			t.Fatalf("%s: %v", w.String(), err)
+		}
+		w.Reset()
+		return res
+	}
+	fn := "github.com/scott-cotton/L_test.TestStack"
+
+	l.Dict().Field("a", 1).Log()
+	file, line := here()
+	res := decode()
+	want := stackFrame{File: file, Line: line - 1, Func: fn}
+	if res.Lcaller == nil || *res.Lcaller != want {
+		t.Errorf("Caller(): got %v want %v", res.Lcaller, want)
+	}
+	if res.Lstack != nil {
+		t.Errorf("stack without error: %v", res.Lstack)
+	}
+
+	l.Dict().Err(errors.New("x")).Log()
+	file, line = here()
+	res = decode()
+	if len(res.Lstack) < 2 {
+		t.Fatalf("short stack: %v", res.Lstack)
+	}
+	want.Line = line - 1
+	if res.Lstack[0] != want {
+		t.Errorf("StackOnErr(): got %v want %v", res.Lstack[0], want)
+	}
+	if !strings.HasPrefix(res.Lstack[1].Func, "testing.") {
+		t.Errorf("StackOnErr(): got %v", res.Lstack[1])
+	}
+
+	// without middlewares
+	cfg.Post = nil
+	l = L.New(cfg)
+	o := l.Dict().Caller(0)
+	file, line = here()
+	o.Stack().Log()
+	res = decode()
+	want.Line = line - 1
+	if res.Lcaller == nil || *res.Lcaller != want {
+		t.Errorf("Obj.Caller: got %v want %v", res.Lcaller, want)
+	}
+	want.Line = line + 1
+	if len(res.Lstack) == 0 || res.Lstack[0] != want {
+		t.Errorf("Obj.Stack: got %v want %v", res.Lstack, want)
+	}
+
+	func() {
+		l.Dict().Caller(1).Log()
+	}()
+	file, line = here()
+	res = decode()
+	want.Line = line - 1
+	if res.Lcaller == nil || *res.Lcaller != want {
+		t.Errorf("Obj.Caller(1): got %v want %v", res.Lcaller, want)
+	}
+}
@@ -0,0 +1,82 @@
+package L
+
+import "unicode/utf8"
+
+const hex = "0123456789abcdef"
+
+// appendString appends the json encoding of the text 's' to 'd',
+// escaping exactly as encoding/json does.  If 'html' is true, '<', '>'
+// and '&' are also escaped, as by json.Marshal.
+//
+// Invalid UTF-8 is replaced by U+FFFD.
+func appendString[S string | []byte](d []byte, s S, html bool) []byte {
+	d = append(d, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if safeSet[b] && !(html && htmlUnsafe(b)) {
+				i++
+				continue
+			}
+			d = append(d, s[start:i]...)
+			switch b {
+			case '\\', '"':
+				d = append(d, '\\', b)
+			case '\b':
+				d = append(d, '\\', 'b')
+			case '\f':
+				d = append(d, '\\', 'f')
+			case '\n':
+				d = append(d, '\\', 'n')
+			case '\r':
+				d = append(d, '\\', 'r')
+			case '\t':
+				d = append(d, '\\', 't')
+			default:
+				d = append(d, '\\', 'u', '0', '0', hex[b>>4], hex[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		c, size := decodeRune(s[i:])
+		if c == utf8.RuneError && size == 1 {
+			d = append(d, s[start:i]...)
+			d = append(d, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		// U+2028 and U+2029 are valid json but not valid
+		// javascript, and are escaped by encoding/json.
+		if c == '\u2028' || c == '\u2029' {
+			d = append(d, s[start:i]...)
+			d = append(d, '\\', 'u', '2', '0', '2', hex[c&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	d = append(d, s[start:]...)
+	return append(d, '"')
+}
+
+// decodeRune is utf8.DecodeRune for strings or byte slices.
+func decodeRune[S string | []byte](s S) (rune, int) {
+	var b [utf8.UTFMax]byte
+	return utf8.DecodeRune(b[:copy(b[:], s)])
+}
+
+func htmlUnsafe(b byte) bool {
+	return b == '<' || b == '>' || b == '&'
+}
+
+// safeSet holds true for the ASCII bytes which need no
+// escaping in a json string.
+var safeSet = func() (res [utf8.RuneSelf]bool) {
+	for b := 0x20; b < utf8.RuneSelf; b++ {
+		res[b] = b != '"' && b != '\\'
+	}
+	return
+}()
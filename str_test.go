@@ -0,0 +1,101 @@
+package L
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// jsonString encodes 's' with encoding/json.  Older versions of
+// encoding/json write \b and \f as \u0008 and \u000c and invalid
+// UTF-8 as \ufffd; these are normalized to the current forms.
+func jsonString(s string, html bool) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(html)
+	enc.Encode(s)
+	res := strings.TrimSuffix(b.String(), "\n")
+	return strings.NewReplacer(`\\`, `\\`, `\u0008`, `\b`, `\u000c`, `\f`, `\ufffd`, "\ufffd").Replace(res)
+}
+
+var strSeeds = []string{
+	"", "abc", "a\"b\\c", "\x00\x01\x1b\x1f\x7f", "\b\f\n\r\t",
+	"<a href=\"x\">&amp;</a>", "é☃\U0001F600", "�", "\xff\xfe",
+	"a\xc3", "\xed\xa0\x80", "  ", "\xe2\x80",
+}
+
+func TestStr(t *testing.T) {
+	for _, s := range strSeeds {
+		for _, html := range []bool{false, true} {
+			o := &Obj{opts: objOpts{escapeHTML: html}}
+			o.Str(s)
+			if err := o.Close(); err != nil {
+				t.Errorf("%q: %v", s, err)
+			}
+			if got, exp := string(o.D()), jsonString(s, html); got != exp {
+				t.Errorf("%q html=%t: got %s exp %s", s, html, got, exp)
+			}
+		}
+	}
+}
+
+func TestEscapeHTMLApply(t *testing.T) {
+	c := &Config{}
+	for _, tc := range []struct {
+		mod  string
+		want bool
+	}{
+		{`{"escapeHTML":true}`, true},
+		{`{"labels":{"a":1}}`, true},
+		{`{"escapeHTML":false}`, false},
+	} {
+		var o Config
+		if err := json.Unmarshal([]byte(tc.mod), &o); err != nil {
+			t.Fatal(err)
+		}
+		c.Apply(&o, nil)
+		if got := c.Clone().objOpts().escapeHTML; got != tc.want {
+			t.Errorf("after %s: got %t", tc.mod, got)
+		}
+	}
+}
+
+func FuzzStr(f *testing.F) {
+	for _, s := range strSeeds {
+		f.Add(s, false)
+	}
+	f.Fuzz(func(t *testing.T, s string, html bool) {
+		o := &Obj{opts: objOpts{escapeHTML: html}}
+		o.Str(s)
+		if err := o.Close(); err != nil {
+			t.Fatalf("%q: %v", s, err)
+		}
+		if got, exp := string(o.D()), jsonString(s, html); got != exp {
+			t.Fatalf("%q html=%t: got %s exp %s", s, html, got, exp)
+		}
+	})
+}
+
+func FuzzFieldKey(f *testing.F) {
+	for _, s := range strSeeds {
+		f.Add(s, s)
+	}
+	f.Fuzz(func(t *testing.T, k, v string) {
+		o := (&Obj{}).Dict().Field(k, v)
+		if err := o.Close(); err != nil {
+			t.Fatalf("%q: %v", k, err)
+		}
+		exp := "{" + jsonString(k, false) + ":" + jsonString(v, false) + "}"
+		if got := string(o.D()); got != exp {
+			t.Fatalf("got %s exp %s", got, exp)
+		}
+		var m map[string]string
+		if err := json.Unmarshal(o.D(), &m); err != nil {
+			t.Fatal(err)
+		}
+		if len(m) != 1 {
+			t.Fatalf("got %v", m)
+		}
+	})
+}
@@ -0,0 +1,369 @@
+package L
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Syslog severities.
+const (
+	SevEmerg = iota
+	SevAlert
+	SevCrit
+	SevErr
+	SevWarning
+	SevNotice
+	SevInfo
+	SevDebug
+)
+
+// Some syslog facilities.
+const (
+	FacKern   = 0
+	FacUser   = 1
+	FacDaemon = 3
+	FacAuth   = 4
+	FacLocal0 = 16
+	FacLocal1 = 17
+	FacLocal2 = 18
+	FacLocal3 = 19
+	FacLocal4 = 20
+	FacLocal5 = 21
+	FacLocal6 = 22
+	FacLocal7 = 23
+)
+
+// The formats of a SyslogFmter.
+const (
+	Syslog5424 = iota
+	Syslog3164
+)
+
+// SyslogLevel associates a facility and severity with the values of a
+// level label of at least Min.  A zero Facility, FacKern, keeps the
+// default facility of the SyslogFmter, since kern is reserved for the
+// kernel.
+type SyslogLevel struct {
+	Min      int `json:"min,omitempty"`
+	Facility int `json:"facility,omitempty"`
+	Severity int `json:"severity,omitempty"`
+}
+
+// SyslogFmter is a Fmter writing objects as syslog messages, in the
+// format of RFC 5424 or RFC 3164.  In RFC 5424 format, the object is
+// written as the MSG unless StructuredData is set, in which case it is
+// written as the parameter "json" of the SD-ELEMENT with id SDID and the
+// MSG is the text of the field with key MsgKey, if any.
+//
+// The timestamp is taken from the RFC3339 time in the field with key
+// TimeKey if there is one, and is otherwise the current time.
+//
+// If LevelKey is set, the value of the field with that key, such as a
+// label added by the Label middleware, selects the facility and severity
+// from Levels: those of the last entry whose Min is at most the value.
+// Otherwise, Facility and Severity are used.
+//
+// Each message is written with a single call to Write, followed by a
+// newline which SyslogWriter removes.
+type SyslogFmter struct {
+	// Format is Syslog5424 or Syslog3164.
+	Format int `json:"format,omitempty"`
+	// Facility and Severity are the defaults for messages, FacUser
+	// and SevInfo if both are zero.
+	Facility int `json:"facility,omitempty"`
+	Severity int `json:"severity,omitempty"`
+	// LevelKey and Levels determine the facility and severity from a
+	// label.
+	LevelKey string        `json:"levelKey,omitempty"`
+	Levels   []SyslogLevel `json:"levels,omitempty"`
+	// Hostname, AppName and ProcID default to os.Hostname, the base
+	// name of os.Args[0] and the process id.  MsgID defaults to "-".
+	Hostname string `json:"hostname,omitempty"`
+	AppName  string `json:"appName,omitempty"`
+	ProcID   string `json:"procID,omitempty"`
+	MsgID    string `json:"msgID,omitempty"`
+	// StructuredData places the object in the STRUCTURED-DATA of
+	// RFC 5424 messages.
+	StructuredData bool `json:"structuredData,omitempty"`
+	// SDID is the SD-ID of the structured data, "L@32473" if empty.
+	SDID string `json:"sdid,omitempty"`
+	// TimeKey and MsgKey are "time" and "msg" if empty.
+	TimeKey string `json:"timeKey,omitempty"`
+	MsgKey  string `json:"msgKey,omitempty"`
+
+	once sync.Once
+	host string
+	app  string
+	proc string
+}
+
+func (f *SyslogFmter) init() {
+	f.host = f.Hostname
+	if f.host == "" {
+		f.host, _ = os.Hostname()
+	}
+	f.app = f.AppName
+	if f.app == "" && len(os.Args) > 0 {
+		f.app = filepath.Base(os.Args[0])
+	}
+	f.proc = f.ProcID
+	if f.proc == "" {
+		f.proc = strconv.Itoa(os.Getpid())
+	}
+}
+
+// syslogState holds the buffers of a call to SyslogFmter.Fmt.
+type syslogState struct {
+	sc  Scanner
+	tmp []byte
+	buf []byte
+}
+
+var syslogPool = sync.Pool{
+	New: func() any { return &syslogState{} },
+}
+
+func (f *SyslogFmter) Fmt(w io.Writer, d []byte) error {
+	f.once.Do(f.init)
+	st := syslogPool.Get().(*syslogState)
+	defer syslogPool.Put(st)
+	tv, msg, level := st.fields(d, orDefault(f.TimeKey, "time"), orDefault(f.MsgKey, "msg"), f.LevelKey)
+	fac, sev := f.priority(level)
+	ts := time.Now()
+	if len(tv) > 0 && tv[0] == '"' {
+		st.tmp = AppendUnquote(st.tmp[:0], tv)
+		if t, err := time.Parse(time.RFC3339Nano, string(st.tmp)); err == nil {
+			ts = t
+		}
+	}
+	buf := st.buf[:0]
+	buf = append(buf, '<')
+	buf = strconv.AppendInt(buf, int64(fac*8+sev), 10)
+	buf = append(buf, '>')
+	if f.Format == Syslog3164 {
+		buf = ts.AppendFormat(buf, time.Stamp)
+		buf = append(buf, ' ')
+		buf = appendSyslogName(buf, f.host, 255)
+		buf = append(buf, ' ')
+		buf = appendSyslogName(buf, f.app, 32)
+		buf = append(buf, '[')
+		buf = appendSyslogName(buf, f.proc, 128)
+		buf = append(buf, "]: "...)
+		buf = append(buf, d...)
+	} else {
+		buf = append(buf, "1 "...)
+		buf = ts.AppendFormat(buf, "2006-01-02T15:04:05.000000Z07:00")
+		for _, s := range [...]struct {
+			v   string
+			max int
+		}{{f.host, 255}, {f.app, 48}, {f.proc, 128}, {f.MsgID, 32}} {
+			buf = append(buf, ' ')
+			buf = appendSyslogName(buf, s.v, s.max)
+		}
+		buf = append(buf, ' ')
+		if f.StructuredData {
+			buf = append(buf, '[')
+			buf = appendSyslogName(buf, orDefault(f.SDID, "L@32473"), 32)
+			buf = append(buf, ` json="`...)
+			for _, c := range d {
+				if c == '"' || c == '\\' || c == ']' {
+					buf = append(buf, '\\')
+				}
+				buf = append(buf, c)
+			}
+			buf = append(buf, `"]`...)
+			if len(msg) > 0 {
+				buf = append(buf, ' ')
+				if msg[0] == '"' {
+					buf = AppendUnquote(buf, msg)
+				} else {
+					buf = append(buf, msg...)
+				}
+			}
+		} else {
+			buf = append(buf, "- "...)
+			buf = append(buf, d...)
+		}
+	}
+	buf = append(buf, '\n')
+	st.buf = buf
+	_, err := w.Write(buf)
+	return err
+}
+
+// fields returns the values of the first fields with keys 'timeKey',
+// 'msgKey' and 'levelKey' in 'd', if it is a dict.
+func (st *syslogState) fields(d []byte, timeKey, msgKey, levelKey string) (tv, msg, level []byte) {
+	i := skipSpace(d, 0)
+	if i == len(d) || d[i] != '{' {
+		return nil, nil, nil
+	}
+	sc := &st.sc
+	sc.resetAt(d, i)
+	for m, ok := sc.field(); ok; m, ok = sc.field() {
+		k, v := d[m.ks:m.ke], d[m.vs:m.ve]
+		if tv == nil && keyIs(k, timeKey) {
+			tv = v
+		}
+		if msg == nil && keyIs(k, msgKey) {
+			msg = v
+		}
+		if level == nil && levelKey != "" && keyIs(k, levelKey) {
+			level = v
+		}
+	}
+	return tv, msg, level
+}
+
+// priority returns the facility and severity for the value 'level' of
+// the field with key LevelKey, if any.
+func (f *SyslogFmter) priority(level []byte) (fac, sev int) {
+	fac, sev = f.Facility, f.Severity
+	if fac == 0 && sev == 0 {
+		fac, sev = FacUser, SevInfo
+	}
+	if level != nil {
+		if n, err := strconv.Atoi(string(level)); err == nil {
+			for _, l := range f.Levels {
+				if l.Min <= n {
+					sev = l.Severity
+					if l.Facility != 0 {
+						fac = l.Facility
+					}
+				}
+			}
+		}
+	}
+	if fac < 0 || fac > FacLocal7 {
+		fac = FacUser
+	}
+	if sev < 0 || sev > SevDebug {
+		sev = SevInfo
+	}
+	return fac, sev
+}
+
+// appendSyslogName appends 's', truncated to 'max' bytes and with
+// characters other than printable ascii replaced by '_', or "-" if 's' is
+// empty.
+func appendSyslogName(buf []byte, s string, max int) []byte {
+	if s == "" {
+		return append(buf, '-')
+	}
+	if len(s) > max {
+		s = s[:max]
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c >= 0x7f {
+			c = '_'
+		}
+		buf = append(buf, c)
+	}
+	return buf
+}
+
+// SyslogWriter is an io.WriteCloser sending each Write to a syslog
+// daemon as one message, for example as formatted by SyslogFmter.  A
+// trailing newline is removed.  Over stream connections, messages are
+// framed by octet counting as in RFC 6587.  If a Write fails, the
+// connection is redialed once.
+//
+// A SyslogWriter is safe for concurrent use.
+type SyslogWriter struct {
+	network string
+	addr    string
+	stream  bool // set by DialSyslog, and read without mu
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// DialSyslog connects to a syslog daemon at 'addr' on 'network', which
+// may be "unixgram", "unix", "udp" or "tcp".  If 'network' is "unixgram"
+// and 'addr' is empty, the local daemon at /dev/log, /var/run/syslog or
+// /var/run/log is used.
+func DialSyslog(network, addr string) (*SyslogWriter, error) {
+	switch network {
+	case "unixgram", "udp", "udp4", "udp6":
+	case "unix", "tcp", "tcp4", "tcp6":
+	default:
+		return nil, errors.New("unsupported syslog network: " + network)
+	}
+	w := &SyslogWriter{
+		network: network,
+		addr:    addr,
+		stream:  network == "unix" || network[:3] == "tcp",
+	}
+	if err := w.dial(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *SyslogWriter) dial() error {
+	if w.network == "unixgram" && w.addr == "" {
+		var err error
+		for _, p := range []string{"/dev/log", "/var/run/syslog", "/var/run/log"} {
+			var c net.Conn
+			if c, err = net.Dial("unixgram", p); err == nil {
+				w.conn = c
+				return nil
+			}
+		}
+		return err
+	}
+	c, err := net.Dial(w.network, w.addr)
+	if err != nil {
+		return err
+	}
+	w.conn = c
+	return nil
+}
+
+func (w *SyslogWriter) Write(p []byte) (int, error) {
+	msg := bytes.TrimSuffix(p, []byte{'\n'})
+	if w.stream {
+		framed := make([]byte, 0, len(msg)+8)
+		framed = strconv.AppendInt(framed, int64(len(msg)), 10)
+		framed = append(framed, ' ')
+		msg = append(framed, msg...)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		if err := w.dial(); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := w.conn.Write(msg); err != nil {
+		w.conn.Close()
+		if err := w.dial(); err != nil {
+			w.conn = nil
+			return 0, err
+		}
+		if _, err := w.conn.Write(msg); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Close closes the connection of 'w'.
+func (w *SyslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
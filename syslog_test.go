@@ -0,0 +1,157 @@
+package L
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogFmter(t *testing.T) {
+	rec := `{"time":"2022-01-02T03:04:05.123Z","msg":"hi","lvl":2}`
+	sd := strings.ReplaceAll(rec, `"`, `\"`)
+	for _, tc := range []struct {
+		f    func(*SyslogFmter)
+		want string
+	}{
+		{
+			func(*SyslogFmter) {},
+			`<14>1 2022-01-02T03:04:05.123000Z h app 42 - - ` + rec,
+		},
+		{
+			func(f *SyslogFmter) {
+				f.StructuredData = true
+				f.MsgID = "m id"
+			},
+			`<14>1 2022-01-02T03:04:05.123000Z h app 42 m_id [L@32473 json="` + sd + `"] hi`,
+		},
+		{
+			func(f *SyslogFmter) {
+				f.LevelKey = "lvl"
+				f.Levels = []SyslogLevel{{0, FacLocal0, SevDebug}, {2, FacLocal0, SevErr}, {3, FacLocal0, SevCrit}}
+			},
+			`<131>1 2022-01-02T03:04:05.123000Z h app 42 - - ` + rec,
+		},
+		{
+			// levels without a facility keep the default.
+			func(f *SyslogFmter) {
+				f.LevelKey = "lvl"
+				f.Levels = []SyslogLevel{{Min: 2, Severity: SevErr}}
+			},
+			`<11>1 2022-01-02T03:04:05.123000Z h app 42 - - ` + rec,
+		},
+		{
+			func(f *SyslogFmter) {
+				f.Facility, f.Severity = FacDaemon, SevWarning
+				f.LevelKey = "lvl"
+				f.Levels = []SyslogLevel{{Min: 2, Severity: SevErr}}
+			},
+			`<27>1 2022-01-02T03:04:05.123000Z h app 42 - - ` + rec,
+		},
+		{
+			func(f *SyslogFmter) {
+				f.Format = Syslog3164
+				f.Facility, f.Severity = FacDaemon, SevWarning
+			},
+			`<28>Jan  2 03:04:05 h app[42]: ` + rec,
+		},
+	} {
+		f := &SyslogFmter{Hostname: "h", AppName: "app", ProcID: "42"}
+		tc.f(f)
+		w := bytes.NewBuffer(nil)
+		if err := f.Fmt(w, []byte(rec)); err != nil {
+			t.Fatal(err)
+		}
+		if got := w.String(); got != tc.want+"\n" {
+			t.Errorf("got  %s\nwant %s", got, tc.want)
+		}
+	}
+}
+
+func TestSyslogWriter(t *testing.T) {
+	msgs := []string{"<14>1 - - - - - {\"a\":1}\n", "<14>1 - - - - - {\"b\":\n2}\n"}
+
+	// datagram sockets
+	sock := filepath.Join(t.TempDir(), "log")
+	for _, nw := range []struct{ network, addr string }{{"udp", "127.0.0.1:0"}, {"unixgram", sock}} {
+		pc, err := net.ListenPacket(nw.network, nw.addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer pc.Close()
+		w, err := DialSyslog(nw.network, pc.LocalAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range msgs {
+			if _, err := w.Write([]byte(m)); err != nil {
+				t.Fatal(err)
+			}
+			buf := make([]byte, 1024)
+			pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+			n, _, err := pc.ReadFrom(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := string(buf[:n]), strings.TrimSuffix(m, "\n"); got != want {
+				t.Errorf("%s: got %q want %q", nw.network, got, want)
+			}
+		}
+		w.Close()
+	}
+
+	// octet counting over tcp
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	got := make(chan string, len(msgs))
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		r := bufio.NewReader(c)
+		for {
+			n, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			size, _ := strconv.Atoi(strings.TrimSuffix(n, " "))
+			b := make([]byte, size)
+			if _, err := io.ReadFull(r, b); err != nil {
+				return
+			}
+			got <- string(b)
+		}
+	}()
+	w, err := DialSyslog("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	for _, m := range msgs {
+		w.Write([]byte(m))
+	}
+	for _, m := range msgs {
+		select {
+		case g := <-got:
+			if want := strings.TrimSuffix(m, "\n"); g != want {
+				t.Errorf("tcp: got %q want %q", g, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout")
+		}
+	}
+
+	if _, err := DialSyslog("sctp", ""); err == nil {
+		t.Error("expected error")
+	}
+}
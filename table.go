@@ -6,57 +6,353 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
 )
 
+// The modes of a TableFmter.
+const (
+	// TableText writes fields separated by TableFmter.Sep.
+	TableText = iota
+	// TableCSV writes comma separated values, quoted as in RFC 4180.
+	TableCSV
+	// TableTSV writes tab separated values, quoted as in TableCSV.
+	TableTSV
+)
+
+// TableFmter is a Fmter writing a line of selected fields for each
+// dict.  Fields are selected by dotted paths as for Obj.Lookup, such as
+// "http.status" or "Lerr.msg".  Strings are written without quotes,
+// numbers as they appear in the json unless FloatFmt is set, and dicts and
+// arrays as json.  A field which is missing or null is written as
+// Missing.
+//
+// A TableFmter may be shared by several loggers.
 type TableFmter struct {
-	Fields    []string
-	Sep       string
-	Keys      bool
-	FloatFmt  byte
-	FloatPrec int
-	buf       *bytes.Buffer
+	// Fields are the paths of the fields to write.
+	Fields []string `json:"fields,omitempty"`
+	// Sep separates fields in TableText mode, " " if empty.
+	Sep string `json:"sep,omitempty"`
+	// Keys causes fields to be written as path=value in TableText
+	// mode.
+	Keys bool `json:"keys,omitempty"`
+	// FloatFmt and FloatPrec, if FloatFmt is not 0, reformat numbers
+	// with a fraction or exponent as for strconv.FormatFloat.  In json,
+	// FloatFmt is a string such as "f".
+	FloatFmt  byte `json:"floatFmt,omitempty"`
+	FloatPrec int  `json:"floatPrec,omitempty"`
+	// Widths gives fixed widths of the columns in TableText mode.
+	// Shorter values are padded with spaces and longer values are
+	// truncated, ending with "…".  A width of zero is not fixed.
+	Widths []int `json:"widths,omitempty"`
+	// AutoWidth pads each column without a fixed width in TableText
+	// mode to the width of the widest value written so far.
+	AutoWidth bool `json:"autoWidth,omitempty"`
+	// Missing is written for missing fields, "-" if empty in
+	// TableText mode.
+	Missing string `json:"missing,omitempty"`
+	// Header causes the Fields to be written as a header line before
+	// the first line.
+	Header bool `json:"header,omitempty"`
+	// Mode is one of TableText, TableCSV or TableTSV.
+	Mode int `json:"mode,omitempty"`
+
+	mu     sync.Mutex
+	header bool
+	widths []int
+}
+
+// tableFmter is TableFmter without its methods.
+type tableFmter TableFmter
+
+// tableJSON is the json encoding of a TableFmter, with FloatFmt as a
+// string.
+type tableJSON struct {
+	*tableFmter
+	FloatFmt string `json:"floatFmt,omitempty"`
+}
+
+func (c *TableFmter) MarshalJSON() ([]byte, error) {
+	v := tableJSON{tableFmter: (*tableFmter)(c)}
+	if c.FloatFmt != 0 {
+		v.FloatFmt = string(rune(c.FloatFmt))
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes 'd' with DecodeParams, so unknown fields are an
+// error.
+func (c *TableFmter) UnmarshalJSON(d []byte) error {
+	v := tableJSON{tableFmter: (*tableFmter)(c)}
+	if err := DecodeParams(d, &v); err != nil {
+		return err
+	}
+	switch {
+	case v.FloatFmt == "":
+		c.FloatFmt = 0
+	case len(v.FloatFmt) == 1 && strings.Contains("beEfgGxX", v.FloatFmt):
+		c.FloatFmt = v.FloatFmt[0]
+	default:
+		return fmt.Errorf("invalid floatFmt %q", v.FloatFmt)
+	}
+	return nil
 }
 
 func (c *TableFmter) Fmt(w io.Writer, d []byte) error {
-	if c.buf == nil {
-		c.buf = bytes.NewBuffer(nil)
-	} else {
-		c.buf.Reset()
-	}
-	j := map[string]any{}
-	json.Unmarshal(d, &j)
-	enc := json.NewEncoder(c.buf)
-	i := 0
-	for _, f := range c.Fields {
-		v := j[f]
-		if v == nil {
+	st := tablePool.Get().(*tableState)
+	defer tablePool.Put(st)
+	st.find(d, c.Fields)
+	c.mu.Lock()
+	buf := st.buf[:0]
+	if c.Header && !c.header {
+		c.header = true
+		for i, f := range c.Fields {
+			st.tmp = append(st.tmp[:0], f...)
+			buf = c.cell(buf, st.tmp, i, true)
+		}
+	}
+	for i := range c.Fields {
+		st.tmp = c.text(st.tmp[:0], d, st.spans[i])
+		buf = c.cell(buf, st.tmp, i, false)
+	}
+	c.mu.Unlock()
+	st.buf = buf
+	_, e := w.Write(buf)
+	return e
+}
+
+// tableState holds the buffers of a call to TableFmter.Fmt.
+type tableState struct {
+	sc     Scanner
+	spans  []span
+	path   []byte
+	frames []pathFrame
+	tmp    []byte
+	buf    []byte
+}
+
+// span is the offsets of a value, with start -1 for none.
+type span struct {
+	start, end int
+}
+
+// pathFrame is an open dict or array when tracking the dotted paths of
+// values with a Scanner.
+type pathFrame struct {
+	start int // the offset of the dict or array
+	base  int // the length of its path
+	n     int // the number of elements or fields
+	dict  bool
+}
+
+var tablePool = sync.Pool{
+	New: func() any { return &tableState{} },
+}
+
+// find sets st.spans to the values at the dotted paths 'fields' in 'd', as
+// with lookup, in one pass.
+func (st *tableState) find(d []byte, fields []string) {
+	st.spans = st.spans[:0]
+	for range fields {
+		st.spans = append(st.spans, span{start: -1})
+	}
+	st.path = st.path[:0]
+	st.frames = st.frames[:0]
+	sc := &st.sc
+	sc.Reset(d)
+	todo := len(fields)
+	for todo > 0 {
+		tok := sc.Next()
+		switch tok.Kind {
+		case KindEnd:
+			return
+		case KindDictEnd, KindArrayEnd:
+			st.frames = st.frames[:len(st.frames)-1]
+			continue
+		case KindKey:
+			fr := &st.frames[len(st.frames)-1]
+			st.path = st.path[:fr.base]
+			if fr.base > 0 {
+				st.path = append(st.path, '.')
+			}
+			st.path = AppendUnquote(st.path, d[tok.Start:tok.End])
 			continue
 		}
-		if i != 0 {
-			fmt.Fprintf(c.buf, c.Sep)
-		}
-		i++
-		if c.Keys {
-			fmt.Fprintf(c.buf, "%s=", f)
-		}
-		switch x := v.(type) {
-		case string:
-			fmt.Fprint(c.buf, x)
-		case int:
-			fmt.Fprint(c.buf, strconv.Itoa(x))
-		case float64:
-			fmt.Fprint(c.buf, strconv.FormatFloat(x, c.FloatFmt, c.FloatPrec, 64))
-		case bool:
-			fmt.Fprint(c.buf, strconv.FormatBool(x))
-		default:
-			if e := enc.Encode(v); e != nil {
-				return e
+		if n := len(st.frames); n > 0 && !st.frames[n-1].dict {
+			fr := &st.frames[n-1]
+			st.path = st.path[:fr.base]
+			if fr.base > 0 {
+				st.path = append(st.path, '.')
+			}
+			st.path = strconv.AppendInt(st.path, int64(fr.n), 10)
+			fr.n++
+		}
+		container := tok.Kind == KindDict || tok.Kind == KindArray
+		found, prefix := false, false
+		for i, f := range fields {
+			switch {
+			case st.spans[i].start != -1:
+			case f == string(st.path):
+				found = true
+				st.spans[i].start = tok.Start
+				todo--
+			case isPathPrefix(st.path, f):
+				prefix = true
+			}
+		}
+		end := tok.End
+		if container && !prefix {
+			end = sc.Skip(tok)
+		} else if container {
+			st.frames = append(st.frames, pathFrame{start: tok.Start, base: len(st.path), dict: tok.Kind == KindDict})
+			if found {
+				end = skipValue(d, tok.Start)
+			}
+		}
+		if !found {
+			continue
+		}
+		for i := range st.spans {
+			if st.spans[i].start == tok.Start {
+				st.spans[i].end = end
 			}
-			// remove trailing newline
-			c.buf.Truncate(c.buf.Len() - 1)
 		}
 	}
-	fmt.Fprintln(c.buf)
-	_, e := w.Write(c.buf.Bytes())
-	return e
+}
+
+// isPathPrefix returns whether the dotted path 'p' is a proper prefix
+// of 'f'.
+func isPathPrefix(p []byte, f string) bool {
+	if len(p) == 0 {
+		return true
+	}
+	return len(f) > len(p) && f[len(p)] == '.' && f[:len(p)] == string(p)
+}
+
+// text appends the text of the value at 's' in 'd' to 'dst'.
+func (c *TableFmter) text(dst, d []byte, s span) []byte {
+	if s.start == -1 || s.start == s.end || string(d[s.start:s.end]) == "null" {
+		if c.Missing == "" && c.Mode == TableText {
+			return append(dst, '-')
+		}
+		return append(dst, c.Missing...)
+	}
+	v := d[s.start:s.end]
+	switch v[0] {
+	case '"':
+		return AppendUnquote(dst, v)
+	case '{', '[', 't', 'f':
+		return append(dst, v...)
+	}
+	if c.FloatFmt != 0 && bytes.ContainsAny(v, ".eE") {
+		if f, err := strconv.ParseFloat(string(v), 64); err == nil {
+			return strconv.AppendFloat(dst, f, c.FloatFmt, c.FloatPrec, 64)
+		}
+	}
+	return append(dst, v...)
+}
+
+// cell appends the text 'v' of column 'i' to 'buf', followed by a newline
+// if it is the last column.
+func (c *TableFmter) cell(buf, v []byte, i int, header bool) []byte {
+	last := i == len(c.Fields)-1
+	if c.Mode != TableText {
+		comma := byte(',')
+		if c.Mode == TableTSV {
+			comma = '\t'
+		}
+		if i != 0 {
+			buf = append(buf, comma)
+		}
+		buf = appendCSV(buf, v, comma)
+		if last {
+			buf = append(buf, '\n')
+		}
+		return buf
+	}
+	if i != 0 {
+		sep := c.Sep
+		if sep == "" {
+			sep = " "
+		}
+		buf = append(buf, sep...)
+	}
+	if c.Keys && !header {
+		buf = append(buf, c.Fields[i]...)
+		buf = append(buf, '=')
+	}
+	if c.AutoWidth && len(c.widths) < len(c.Fields) {
+		c.widths = append(c.widths, make([]int, len(c.Fields)-len(c.widths))...)
+	}
+	width := 0
+	if i < len(c.Widths) {
+		width = c.Widths[i]
+	}
+	v = truncate(v, width)
+	n := utf8.RuneCount(v)
+	if width == 0 && c.AutoWidth {
+		if n > c.widths[i] {
+			c.widths[i] = n
+		}
+		width = c.widths[i]
+	}
+	buf = append(buf, v...)
+	if last {
+		return append(buf, '\n')
+	}
+	for ; n < width; n++ {
+		buf = append(buf, ' ')
+	}
+	return buf
+}
+
+// truncate truncates 'v' to 'max' runes, ending with "…", if 'max' is
+// positive.
+func truncate(v []byte, max int) []byte {
+	if max <= 0 || utf8.RuneCount(v) <= max {
+		return v
+	}
+	n := 0
+	for i := 0; i < len(v); {
+		if n == max-1 {
+			return append(v[:i], "…"...)
+		}
+		_, size := utf8.DecodeRune(v[i:])
+		i += size
+		n++
+	}
+	return v
+}
+
+// appendCSV appends 'v' as a field separated by 'comma', quoted as in
+// RFC 4180 if necessary.
+func appendCSV(buf, v []byte, comma byte) []byte {
+	if !csvNeedsQuotes(v, comma) {
+		return append(buf, v...)
+	}
+	buf = append(buf, '"')
+	for _, c := range v {
+		if c == '"' {
+			buf = append(buf, '"')
+		}
+		buf = append(buf, c)
+	}
+	return append(buf, '"')
+}
+
+// csvNeedsQuotes is as in encoding/csv.
+func csvNeedsQuotes(v []byte, comma byte) bool {
+	if len(v) == 0 {
+		return false
+	}
+	if string(v) == `\.` {
+		return true
+	}
+	if bytes.IndexByte(v, comma) != -1 || bytes.ContainsAny(v, "\"\r\n") {
+		return true
+	}
+	r, _ := utf8.DecodeRune(v)
+	return unicode.IsSpace(r)
 }
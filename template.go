@@ -0,0 +1,160 @@
+package L
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+// TemplateFmter is a Fmter which executes a text/template for each
+// object.  The template is given the decoded object, a map[string]any
+// for dicts, with numbers decoded as json.Number.  The output of the
+// template is followed by a newline unless it ends with one.  Since
+// text/template works on Go values, each object is decoded rather than
+// read with a Scanner, which allocates in proportion to its size.
+//
+// In addition to the builtin functions of text/template, the following
+// functions are available.
+//
+//   - json: the compact json encoding of its argument, as in
+//     {{json .http}}.
+//   - pad: its second argument formatted as with {{print}} and padded with
+//     spaces to the width given by its first argument, on the left if the
+//     width is negative, as in {{.msg | pad 20}}.
+//   - default: its second argument, unless it is nil or empty, in which
+//     case the first, as in {{.user | default "-"}}.
+//   - time: an RFC3339 time string reformatted with the layout given by
+//     the first argument, as in {{.time | time "15:04:05"}}.  Other values
+//     are returned as they are.
+//   - label: the value of the field for a label, as added by the Label
+//     middleware, of the dict given as its second argument.  A label
+//     starting with '.' matches the field with the label as a suffix, as
+//     in {{label ".debug" .}}, or the first such field in sorted order if
+//     there are several.
+//
+// A TemplateFmter may be shared by several loggers.
+type TemplateFmter struct {
+	t *template.Template
+}
+
+// NewTemplateFmter creates a TemplateFmter from the template 'text',
+// returning an error if 'text' is not a valid template.
+func NewTemplateFmter(text string) (*TemplateFmter, error) {
+	t, err := template.New("L").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &TemplateFmter{t: t}, nil
+}
+
+// MustTemplateFmter is as NewTemplateFmter, but panics on error.
+func MustTemplateFmter(text string) *TemplateFmter {
+	f, err := NewTemplateFmter(text)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+func (f *TemplateFmter) Fmt(w io.Writer, d []byte) error {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(d))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := f.t.Execute(&buf, v); err != nil {
+		return err
+	}
+	if b := buf.Bytes(); len(b) == 0 || b[len(b)-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+var templateFuncs = template.FuncMap{
+	"json":    templateJSON,
+	"pad":     templatePad,
+	"default": templateDefault,
+	"time":    templateTime,
+	"label":   templateLabel,
+}
+
+func templateJSON(v any) (string, error) {
+	d, err := json.Marshal(v)
+	return string(d), err
+}
+
+func templatePad(width int, v any) string {
+	s := fmt.Sprint(v)
+	n := utf8.RuneCountInString(s)
+	switch {
+	case width < 0 && n < -width:
+		return strings.Repeat(" ", -width-n) + s
+	case width > 0 && n < width:
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
+
+func templateDefault(dflt, v any) any {
+	switch x := v.(type) {
+	case nil:
+		return dflt
+	case string:
+		if x == "" {
+			return dflt
+		}
+	case map[string]any:
+		if len(x) == 0 {
+			return dflt
+		}
+	case []any:
+		if len(x) == 0 {
+			return dflt
+		}
+	}
+	return v
+}
+
+func templateTime(layout string, v any) any {
+	s, ok := v.(string)
+	if !ok {
+		return v
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return v
+	}
+	return t.Format(layout)
+}
+
+func templateLabel(label string, v any) any {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil
+	}
+	if x, ok := m[label]; ok {
+		return x
+	}
+	if label == "" || label[0] != '.' {
+		return nil
+	}
+	// the least matching key, so that the result does not depend on
+	// the order of the map.
+	var res any
+	first := ""
+	for k, x := range m {
+		if strings.HasSuffix(k, label) && (first == "" || k < first) {
+			first, res = k, x
+		}
+	}
+	return res
+}
@@ -0,0 +1,499 @@
+package L
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ValidationError is the error returned by Obj.Close when the
+// json constructed in an Obj is invalid.
+type ValidationError struct {
+	// Offset is the offset of the error in the buffer of
+	// the root Obj.
+	Offset int
+	// Path is the path to the value containing the error,
+	// in the form of dot separated object keys and array
+	// indices, such as "a.b.0.c".
+	Path string
+	// Msg describes the error.
+	Msg string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("%s at offset %d", e.Msg, e.Offset)
+	}
+	return fmt.Sprintf("%s at offset %d (path %s)", e.Msg, e.Offset, e.Path)
+}
+
+// validator states.
+const (
+	stValue      = iota // expecting a value
+	stValueOrEnd        // after '[', expecting a value or ']'
+	stKeyOrEnd          // after '{', expecting a key or '}'
+	stKey               // after ',' in an object, expecting a key
+	stColon             // after a key, expecting ':'
+	stAfterValue        // after a value in a container, expecting ',' or its end
+	stDone              // after a top-level value
+	stString            // in a string
+	stStringEsc         // after '\' in a string
+	stStringU           // in a \u escape, with v.n hex digits remaining
+	stNumNeg            // after '-'
+	stNumZero           // after a leading '0'
+	stNumInt            // in integer digits
+	stNumDot            // after '.'
+	stNumFrac           // in fraction digits
+	stNumE              // after 'e' or 'E'
+	stNumESign          // after the sign of an exponent
+	stNumExp            // in exponent digits
+	stLiteral           // in the literal v.lit, at index v.n
+	stError
+)
+
+// validator incrementally validates json as it is appended to the buffer
+// of an Obj.  Values appended by the methods of Obj are known to be valid
+// and are accepted in constant time, other bytes are scanned once.
+//
+// validator does not allocate unless nesting exceeds 64 levels.
+type validator struct {
+	off   int // the offset up to which the buffer has been validated
+	state uint8
+	key   bool // whether the current string is an object key
+	n     int
+	lit   string
+	// stack holds a bit for each of the first 64 levels of nesting,
+	// set for objects, and more holds the deeper levels.
+	stack uint64
+	depth int
+	more  []bool
+}
+
+func (v *validator) push(obj bool) {
+	if v.depth < 64 {
+		if obj {
+			v.stack |= 1 << v.depth
+		} else {
+			v.stack &^= 1 << v.depth
+		}
+	} else {
+		v.more = append(v.more, obj)
+	}
+	v.depth++
+}
+
+func (v *validator) pop() {
+	v.depth--
+	if v.depth >= 64 {
+		v.more = v.more[:len(v.more)-1]
+	}
+}
+
+func (v *validator) inObj() bool {
+	i := v.depth - 1
+	if i >= 64 {
+		return v.more[i-64]
+	}
+	return v.stack&(1<<i) != 0
+}
+
+func (v *validator) clone() validator {
+	res := *v
+	if v.more != nil {
+		res.more = append([]bool(nil), v.more...)
+	}
+	return res
+}
+
+func (v *validator) endValue() {
+	if v.depth == 0 {
+		v.state = stDone
+		return
+	}
+	v.state = stAfterValue
+}
+
+// scan validates d[v.off:], returning the offset of any error and
+// a message describing it.
+func (v *validator) scan(d []byte) (int, string) {
+	for v.off < len(d) {
+		if v.state == stString {
+			// fast path for the body of a string.
+			j := v.off
+			for j < len(d) {
+				c := d[j]
+				if c == '"' || c == '\\' || c < 0x20 {
+					break
+				}
+				j++
+			}
+			v.off = j
+			if j == len(d) {
+				break
+			}
+		}
+		if msg := v.step(d[v.off]); msg != "" {
+			v.state = stError
+			return v.off, msg
+		}
+		v.off++
+	}
+	return 0, ""
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// step advances v by the byte 'c', returning a non-empty
+// message on error.
+func (v *validator) step(c byte) string {
+	switch v.state {
+	case stValue, stValueOrEnd:
+		if isSpace(c) {
+			return ""
+		}
+		if c == ']' && v.state == stValueOrEnd {
+			v.pop()
+			v.endValue()
+			return ""
+		}
+		return v.beginValue(c)
+	case stKeyOrEnd, stKey:
+		switch {
+		case isSpace(c):
+		case c == '"':
+			v.state = stString
+			v.key = true
+		case c == '}' && v.state == stKeyOrEnd:
+			v.pop()
+			v.endValue()
+		default:
+			return "invalid character " + quoteChar(c) + " looking for beginning of object key string"
+		}
+		return ""
+	case stColon:
+		switch {
+		case isSpace(c):
+		case c == ':':
+			v.state = stValue
+		default:
+			return "invalid character " + quoteChar(c) + " after object key"
+		}
+		return ""
+	case stAfterValue:
+		obj := v.inObj()
+		switch {
+		case isSpace(c):
+		case c == ',' && obj:
+			v.state = stKey
+		case c == ',':
+			v.state = stValue
+		case c == '}' && obj, c == ']' && !obj:
+			v.pop()
+			v.endValue()
+		case obj:
+			return "invalid character " + quoteChar(c) + " after object key:value pair"
+		default:
+			return "invalid character " + quoteChar(c) + " after array element"
+		}
+		return ""
+	case stDone:
+		if isSpace(c) {
+			return ""
+		}
+		return "invalid character " + quoteChar(c) + " after top-level value"
+	case stString:
+		switch {
+		case c == '"':
+			if v.key {
+				v.key = false
+				v.state = stColon
+			} else {
+				v.endValue()
+			}
+		case c == '\\':
+			v.state = stStringEsc
+		case c < 0x20:
+			return "invalid character " + quoteChar(c) + " in string literal"
+		}
+		return ""
+	case stStringEsc:
+		switch c {
+		case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+			v.state = stString
+		case 'u':
+			v.state = stStringU
+			v.n = 4
+		default:
+			return "invalid character " + quoteChar(c) + " in string escape code"
+		}
+		return ""
+	case stStringU:
+		if !isHex(c) {
+			return "invalid character " + quoteChar(c) + " in \\u hexadecimal character escape"
+		}
+		v.n--
+		if v.n == 0 {
+			v.state = stString
+		}
+		return ""
+	case stNumNeg:
+		switch {
+		case c == '0':
+			v.state = stNumZero
+		case '1' <= c && c <= '9':
+			v.state = stNumInt
+		default:
+			return "invalid character " + quoteChar(c) + " in numeric literal"
+		}
+		return ""
+	case stNumZero, stNumInt:
+		switch {
+		case '0' <= c && c <= '9' && v.state == stNumInt:
+		case c == '.':
+			v.state = stNumDot
+		case c == 'e' || c == 'E':
+			v.state = stNumE
+		default:
+			v.endValue()
+			return v.step(c)
+		}
+		return ""
+	case stNumDot:
+		if '0' <= c && c <= '9' {
+			v.state = stNumFrac
+			return ""
+		}
+		return "invalid character " + quoteChar(c) + " after decimal point in numeric literal"
+	case stNumFrac:
+		switch {
+		case '0' <= c && c <= '9':
+		case c == 'e' || c == 'E':
+			v.state = stNumE
+		default:
+			v.endValue()
+			return v.step(c)
+		}
+		return ""
+	case stNumE:
+		switch {
+		case c == '+' || c == '-':
+			v.state = stNumESign
+		case '0' <= c && c <= '9':
+			v.state = stNumExp
+		default:
+			return "invalid character " + quoteChar(c) + " in exponent of numeric literal"
+		}
+		return ""
+	case stNumESign:
+		if '0' <= c && c <= '9' {
+			v.state = stNumExp
+			return ""
+		}
+		return "invalid character " + quoteChar(c) + " in exponent of numeric literal"
+	case stNumExp:
+		if '0' <= c && c <= '9' {
+			return ""
+		}
+		v.endValue()
+		return v.step(c)
+	case stLiteral:
+		if c != v.lit[v.n] {
+			return "invalid character " + quoteChar(c) + " in literal " + v.lit +
+				" (expecting " + quoteChar(v.lit[v.n]) + ")"
+		}
+		v.n++
+		if v.n == len(v.lit) {
+			v.endValue()
+		}
+		return ""
+	}
+	return "invalid state"
+}
+
+func (v *validator) beginValue(c byte) string {
+	switch {
+	case c == '{':
+		v.push(true)
+		v.state = stKeyOrEnd
+	case c == '[':
+		v.push(false)
+		v.state = stValueOrEnd
+	case c == '"':
+		v.state = stString
+	case c == '-':
+		v.state = stNumNeg
+	case c == '0':
+		v.state = stNumZero
+	case '1' <= c && c <= '9':
+		v.state = stNumInt
+	case c == 't':
+		v.state, v.lit, v.n = stLiteral, "true", 1
+	case c == 'f':
+		v.state, v.lit, v.n = stLiteral, "false", 1
+	case c == 'n':
+		v.state, v.lit, v.n = stLiteral, "null", 1
+	default:
+		return "invalid character " + quoteChar(c) + " looking for beginning of value"
+	}
+	return ""
+}
+
+// accept accepts a complete, valid value in constant time, returning
+// false if the value is not acceptable in the current state.  'str'
+// indicates whether the value is a string.
+func (v *validator) accept(str bool) bool {
+	switch v.state {
+	case stNumZero, stNumInt, stNumFrac, stNumExp:
+		v.endValue()
+	}
+	switch v.state {
+	case stValue, stValueOrEnd:
+		v.endValue()
+		return true
+	case stKeyOrEnd, stKey:
+		if str {
+			v.state = stColon
+			return true
+		}
+	}
+	return false
+}
+
+// complete returns whether a complete top-level value has been
+// scanned.
+func (v *validator) complete() bool {
+	switch v.state {
+	case stNumZero, stNumInt, stNumFrac, stNumExp:
+		if v.depth == 0 {
+			v.state = stDone
+		}
+	}
+	return v.state == stDone
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func quoteChar(c byte) string {
+	if c == '\'' {
+		return `'\''`
+	}
+	if c == '"' {
+		return `'"'`
+	}
+	s := strconv.Quote(string(c))
+	return "'" + s[1:len(s)-1] + "'"
+}
+
+// pathAt returns the path to the innermost value containing the
+// offset 'off' in 'd', which is assumed to be valid json up to 'off'.
+func pathAt(d []byte, off int) string {
+	type frame struct {
+		obj bool
+		key string
+		i   int
+	}
+	var (
+		stack []frame
+		isKey bool
+	)
+	for i := 0; i < off && i < len(d); i++ {
+		switch c := d[i]; c {
+		case '{':
+			stack = append(stack, frame{obj: true})
+			isKey = true
+		case '[':
+			stack = append(stack, frame{})
+			isKey = false
+		case '}', ']':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case ',':
+			if n := len(stack); n > 0 {
+				if stack[n-1].obj {
+					isKey = true
+				} else {
+					stack[n-1].i++
+				}
+			}
+		case ':':
+			isKey = false
+		case '"':
+			j := i + 1
+			for j < off && j < len(d) && d[j] != '"' {
+				if d[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if n := len(stack); n > 0 && isKey && j < len(d) {
+				stack[n-1].key = unquote(d[i : j+1])
+			}
+			i = j
+		}
+	}
+	parts := make([]string, len(stack))
+	for i, f := range stack {
+		if f.obj {
+			parts[i] = f.key
+		} else {
+			parts[i] = strconv.Itoa(f.i)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// unquote returns the string encoded in the json string 's'.
+func unquote(s []byte) string {
+	if len(s) < 2 {
+		return ""
+	}
+	if bytes.IndexByte(s, '\\') == -1 {
+		return string(s[1 : len(s)-1])
+	}
+	return string(AppendUnquote(nil, s))
+}
+
+// validated notes that t's buffer from 'start' to its end holds a
+// single valid json value, a string if 'str'.
+func (t *Obj) validated(start int, str bool) {
+	r := t.getRoot()
+	if r.opts.noValidate || r.err != nil {
+		return
+	}
+	d := r.d
+	off, msg := r.v.scan(d[:start])
+	if msg == "" {
+		if r.v.accept(str) {
+			r.v.off = len(d)
+			return
+		}
+		off, msg = r.v.scan(d)
+	}
+	if msg != "" {
+		r.fail(&ValidationError{Offset: off, Path: pathAt(d, off), Msg: msg})
+	}
+}
+
+// validate validates the buffer of 't', returning any error.  If
+// 'complete' is true, the buffer must contain a complete json value.
+func (t *Obj) validate(complete bool) error {
+	r := t.getRoot()
+	if r.opts.noValidate || r.err != nil {
+		return r.err
+	}
+	d := r.d
+	if off, msg := r.v.scan(d); msg != "" {
+		r.fail(&ValidationError{Offset: off, Path: pathAt(d, off), Msg: msg})
+		return r.err
+	}
+	if complete && !r.v.complete() {
+		r.fail(&ValidationError{Offset: len(d), Path: pathAt(d, len(d)), Msg: "unexpected end of JSON input"})
+	}
+	return r.err
+}
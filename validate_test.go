@@ -0,0 +1,81 @@
+package L
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestValidatorRaw(t *testing.T) {
+	for _, tc := range []string{
+		`{}`, `[]`, `0`, `-1.5e+10`, `"aé\n"`, `true`, `null`,
+		`{"a":[1,2,{"b":false}],"c":"d"}`, ` [ 1 , 2 ] `,
+		`{`, `[1,]`, `{"a"}`, `{"a":}`, `01`, `1.`, `-`, `"\x"`, `tru`,
+		"\"\x01\"", `[1 2]`, `{"a":1,}`, `{1:2}`, `1 2`, `"abc`, `]`,
+	} {
+		// feed the input one byte at a time.
+		o := &Obj{}
+		for i := 0; i < len(tc); i++ {
+			o.Write([]byte{tc[i]})
+			o.validate(false)
+		}
+		err := o.validate(true)
+		if valid := json.Valid([]byte(tc)); valid != (err == nil) {
+			t.Errorf("%q: json.Valid %t, got %v", tc, valid, err)
+		}
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	a := (&Obj{}).Dict().Field("a", 1)
+	a.Write([]byte(`,"b":[1,2,`))
+	err := a.Close()
+	ve, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected *ValidationError, got %v", err)
+	}
+	if ve.Offset != 16 || ve.Path != "b.2" {
+		t.Errorf("got %v", ve)
+	}
+}
+
+func TestNoValidate(t *testing.T) {
+	o := &Obj{opts: objOpts{noValidate: true}}
+	if err := o.Str("a").Str("b").Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNoValidateApply(t *testing.T) {
+	c := &Config{}
+	for _, tc := range []struct {
+		mod  string
+		want bool
+	}{
+		{`{"noValidate":true}`, true},
+		{`{}`, true},
+		{`{"noValidate":false}`, false},
+		{`{}`, false},
+	} {
+		var o Config
+		if err := json.Unmarshal([]byte(tc.mod), &o); err != nil {
+			t.Fatal(err)
+		}
+		c.Apply(&o, nil)
+		if got := c.objOpts().noValidate; got != tc.want {
+			t.Errorf("after %s: got %t", tc.mod, got)
+		}
+	}
+}
+
+func TestValidateDeep(t *testing.T) {
+	o := (&Obj{}).Array()
+	for i := 0; i < 100; i++ {
+		o = o.Array()
+	}
+	for o != nil {
+		if err := o.Close(); err != nil {
+			t.Fatal(err)
+		}
+		o = o.Parent()
+	}
+}